@@ -0,0 +1,166 @@
+package slogbugsnag
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// AutoscaleOptions are options for autoscaling the workers of NotifierWorkers,
+// between MinWorkers and the MaxNotifierConcurrency.
+// Sending bugs is network-bound, so a few workers are usually enough, and
+// more are only needed during bursts, or when bugsnag is slow to respond.
+type AutoscaleOptions struct {
+	// MinWorkers is the number of workers that are always running.
+	// It defaults to 1.
+	MinWorkers int
+
+	// QueueDepth starts another worker when more than this many bugs are
+	// waiting in the queue. It defaults to 10.
+	QueueDepth int
+
+	// WaitTime starts another worker when bugs are waiting in the queue for
+	// longer than this, before a worker picks them up. It defaults to 1 second.
+	WaitTime time.Duration
+
+	// IdleTime stops a worker that has not sent any bugs for this long,
+	// unless only MinWorkers are running. It defaults to 30 seconds.
+	IdleTime time.Duration
+
+	// CheckInterval is how often the queue is checked, to start at most
+	// one more worker each time. It defaults to 100 milliseconds.
+	CheckInterval time.Duration
+}
+
+// autoscaler holds the autoscaling options, and the measurements they are checked against
+type autoscaler struct {
+	minWorkers    int
+	queueDepth    int
+	waitTime      time.Duration
+	idleTime      time.Duration
+	checkInterval time.Duration
+	done          chan struct{}
+
+	// wait is how long the latest bug waited in the queue, and lastDequeue
+	// is when a bug was last taken off the queue, both in nanoseconds
+	wait        atomic.Int64
+	lastDequeue atomic.Int64
+
+	// waitingSince is when the checks first saw bugs waiting in the queue,
+	// with none taken off it since. Only used by the autoscaleLoop.
+	waitingSince time.Time
+
+	scaleUps   atomic.Uint64
+	scaleDowns atomic.Uint64
+}
+
+// newAutoscaler returns an autoscaler with the defaults filled in, or nil if
+// there are no options
+func newAutoscaler(opts *AutoscaleOptions, maxWorkers int) *autoscaler {
+	if opts == nil {
+		return nil
+	}
+	a := &autoscaler{
+		minWorkers:    min(max(opts.MinWorkers, 1), maxWorkers),
+		queueDepth:    opts.QueueDepth,
+		waitTime:      opts.WaitTime,
+		idleTime:      opts.IdleTime,
+		checkInterval: opts.CheckInterval,
+		done:          make(chan struct{}),
+	}
+	if a.queueDepth < 1 {
+		a.queueDepth = 10
+	}
+	if a.waitTime <= 0 {
+		a.waitTime = time.Second
+	}
+	if a.idleTime <= 0 {
+		a.idleTime = 30 * time.Second
+	}
+	if a.checkInterval <= 0 {
+		a.checkInterval = 100 * time.Millisecond
+	}
+	return a
+}
+
+// dequeued records how long a bug, queued at the given time, waited in the queue
+func (a *autoscaler) dequeued(queued time.Time) {
+	now := time.Now()
+	a.lastDequeue.Store(now.UnixNano())
+	if !queued.IsZero() {
+		a.wait.Store(int64(now.Sub(queued)))
+	}
+}
+
+// backedUp returns true if the queue is deeper than the threshold, or if bugs
+// are waiting for longer than the threshold
+func (a *autoscaler) backedUp(queued int, now time.Time) bool {
+	if queued == 0 {
+		a.waitingSince = time.Time{}
+		return false
+	}
+	if queued > a.queueDepth || time.Duration(a.wait.Load()) > a.waitTime {
+		return true
+	}
+
+	// The workers may all be stuck sending, so that no bug is taken off the
+	// queue to measure its wait
+	if a.waitingSince.IsZero() || a.lastDequeue.Load() > a.waitingSince.UnixNano() {
+		a.waitingSince = now
+		return false
+	}
+	return now.Sub(a.waitingSince) > a.waitTime
+}
+
+// resetTimer resets the timer, dropping any tick that was not received
+func resetTimer(t *time.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	t.Reset(d)
+}
+
+// autoscaleLoop checks the queue every CheckInterval, starting another worker
+// if it is backed up, until the NotifierWorkers are closed
+func (nw *NotifierWorkers) autoscaleLoop() {
+	ticker := time.NewTicker(nw.autoscale.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-nw.autoscale.done:
+			return
+		case now := <-ticker.C:
+			if !nw.autoscale.backedUp(nw.queued(), now) {
+				continue
+			}
+			nw.mu.Lock()
+			if !nw.closed() && len(nw.stops) < nw.maxWorkers {
+				nw.startWorker()
+				nw.autoscale.scaleUps.Add(1)
+			}
+			nw.mu.Unlock()
+		}
+	}
+}
+
+// retire stops the idle worker with the stop channel, returning true, unless
+// only the minimum number of workers are running, or the NotifierWorkers
+// are closed and draining their queue
+func (nw *NotifierWorkers) retire(stop chan struct{}) bool {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	if nw.closed() || len(nw.stops) <= nw.autoscale.minWorkers {
+		return false
+	}
+	for i, s := range nw.stops {
+		if s == stop {
+			nw.stops = append(nw.stops[:i], nw.stops[i+1:]...)
+			nw.autoscale.scaleDowns.Add(1)
+			return true
+		}
+	}
+	// Already stopped by SetConcurrency
+	return true
+}
@@ -0,0 +1,76 @@
+package slogbugsnag
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestAutoscale(t *testing.T) {
+	t.Parallel()
+
+	gate := make(chan struct{})
+	nw := NewNotifierWorkers(&NotifierOptions{
+		Notifier:               newGatedBugsnagTestServer(t, gate).Notifier(),
+		MaxNotifierConcurrency: 4,
+		Autoscale: &AutoscaleOptions{
+			QueueDepth:    1,
+			WaitTime:      10 * time.Millisecond,
+			IdleTime:      50 * time.Millisecond,
+			CheckInterval: 5 * time.Millisecond,
+		},
+	})
+	defer nw.Close()
+
+	if stats := nw.Stats(); stats.Workers != 1 || stats.MinWorkers != 1 || stats.MaxWorkers != 4 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	logger := slog.New(NewHandler(&testHandler{}, &HandlerOptions{Notifiers: nw}))
+	for i := 0; i < 10; i++ {
+		logger.Error("slow")
+	}
+
+	// Every worker is stuck sending, so it scales up to the maximum
+	waitForStats(t, "up", nw, func(stats NotifierStats) bool {
+		return stats.Workers == 4 && stats.ScaleUps == 3
+	})
+
+	// Once the bugs are sent, the idle workers stop, down to the minimum
+	close(gate)
+	waitForStats(t, "down", nw, func(stats NotifierStats) bool {
+		return stats.Sent == 10 && stats.Queued == 0 && stats.Workers == 1 && stats.ScaleDowns == 3
+	})
+
+	// Changing the maximum only stops workers above it
+	nw.SetConcurrency(2)
+	if stats := nw.Stats(); stats.Workers != 1 || stats.MinWorkers != 1 || stats.MaxWorkers != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestAutoscaleClose(t *testing.T) {
+	t.Parallel()
+
+	gate := make(chan struct{})
+	nw := NewNotifierWorkers(&NotifierOptions{
+		Notifier:               newGatedBugsnagTestServer(t, gate).Notifier(),
+		MaxNotifierConcurrency: 3,
+		Autoscale:              &AutoscaleOptions{CheckInterval: time.Hour},
+	})
+
+	logger := slog.New(NewHandler(&testHandler{}, &HandlerOptions{Notifiers: nw}))
+	for i := 0; i < 5; i++ {
+		logger.Error("queued")
+	}
+	if stats := nw.Stats(); stats.Workers != 1 || stats.Queued < 4 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// Closing scales up to drain the queue faster
+	time.AfterFunc(20*time.Millisecond, func() { close(gate) })
+	nw.Close()
+	if stats := nw.Stats(); stats.Sent != 5 || stats.ScaleUps != 2 || stats.ScaleDowns != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
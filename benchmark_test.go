@@ -0,0 +1,203 @@
+package slogbugsnag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+)
+
+// discardHandler is a slog.Handler that does nothing, so that benchmarks
+// only measure the work done by our handler.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return true }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// newBenchmarkNotifiers returns NotifierWorkers whose queue is drained without
+// building or sending the bugs, so that benchmarks only measure the log call site.
+func newBenchmarkNotifiers(b *testing.B) *NotifierWorkers {
+	nw := NewNotifierWorkers(&NotifierOptions{Notifier: bugsnag.New(), MaxNotifierConcurrency: 1})
+
+	// Replace the worker, which would build and send the bugs, with ones that
+	// only drain the queues
+	nw.mu.Lock()
+	for _, stop := range nw.stops {
+		close(stop)
+	}
+	nw.stops = nil
+	nw.mu.Unlock()
+	nw.workerWG.Wait()
+	for _, ch := range []chan bugRecord{nw.priorityCh, nw.bugsCh} {
+		nw.workerWG.Add(1)
+		go func(ch chan bugRecord) {
+			defer nw.workerWG.Done()
+			for range ch {
+			}
+		}(ch)
+	}
+	b.Cleanup(nw.Close)
+	return nw
+}
+
+// BenchmarkHandle measures the latency and allocations of Handler.Handle,
+// as seen by the logging goroutine.
+func BenchmarkHandle(b *testing.B) {
+	ctx := context.Background()
+	err := errors.New("terrible error")
+
+	manyAttrs := make([]slog.Attr, 50)
+	for i := range manyAttrs {
+		manyAttrs[i] = slog.Int(fmt.Sprintf("attr%d", i), i)
+	}
+
+	deepGroups := func(h slog.Handler) slog.Handler {
+		for i := 0; i < 10; i++ {
+			h = h.WithAttrs([]slog.Attr{slog.Int("depth", i)}).WithGroup(fmt.Sprintf("group%d", i))
+		}
+		return h
+	}
+
+	tests := []struct {
+		name    string
+		handler func(slog.Handler) slog.Handler
+		level   slog.Level
+		attrs   []slog.Attr
+		forward bool
+	}{
+		{
+			name:    "InfoPassthrough",
+			handler: func(h slog.Handler) slog.Handler { return h },
+			level:   slog.LevelInfo,
+			attrs:   []slog.Attr{slog.String("str", "foo"), slog.Int("int", 1)},
+		},
+		{
+			name: "InfoWithAttrs",
+			handler: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.String("with1", "arg0")})
+			},
+			level: slog.LevelInfo,
+			attrs: []slog.Attr{slog.String("str", "foo"), slog.Int("int", 1)},
+		},
+		{
+			name:    "ErrorNotify",
+			handler: func(h slog.Handler) slog.Handler { return h },
+			level:   slog.LevelError,
+			attrs:   []slog.Attr{slog.Any("err", err), slog.String("str", "foo"), slog.Int("int", 1)},
+		},
+		{
+			name: "ErrorNotifyWithGroup",
+			handler: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.String("with1", "arg0")}).WithGroup("group1")
+			},
+			level: slog.LevelError,
+			attrs: []slog.Attr{slog.Any("err", err), slog.String("str", "foo"), slog.Int("int", 1)},
+		},
+		{
+			name:    "InfoDeepGroups",
+			handler: deepGroups,
+			level:   slog.LevelInfo,
+			attrs:   []slog.Attr{slog.String("str", "foo"), slog.Int("int", 1)},
+		},
+		{
+			name:    "ErrorDeepGroups",
+			handler: deepGroups,
+			level:   slog.LevelError,
+			attrs:   []slog.Attr{slog.Any("err", err), slog.String("str", "foo")},
+		},
+		{
+			name:    "InfoDeepGroupsForwarded",
+			handler: deepGroups,
+			level:   slog.LevelInfo,
+			attrs:   []slog.Attr{slog.String("str", "foo"), slog.Int("int", 1)},
+			forward: true,
+		},
+		{
+			name:    "InfoManyAttrs",
+			handler: func(h slog.Handler) slog.Handler { return h.WithAttrs(manyAttrs) },
+			level:   slog.LevelInfo,
+			attrs:   manyAttrs,
+		},
+		{
+			name:    "ErrorManyAttrs",
+			handler: func(h slog.Handler) slog.Handler { return h.WithAttrs(manyAttrs) },
+			level:   slog.LevelError,
+			attrs:   manyAttrs,
+		},
+	}
+
+	for _, tc := range tests {
+		b.Run(tc.name, func(b *testing.B) {
+			h := tc.handler(NewHandler(discardHandler{}, &HandlerOptions{
+				Notifiers:             newBenchmarkNotifiers(b),
+				ForwardGroupsAndAttrs: tc.forward,
+			}))
+			r := slog.NewRecord(defaultTime, tc.level, "main message", 0)
+			r.AddAttrs(tc.attrs...)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = h.Handle(ctx, r)
+			}
+		})
+	}
+}
+
+// BenchmarkHandleSync is the baseline for the bugs in BenchmarkHandle: the
+// latency of the log call when the bug was built on the logging goroutine,
+// before the NotifierWorkers built them.
+func BenchmarkHandleSync(b *testing.B) {
+	ctx := context.Background()
+	err := errors.New("terrible error")
+	h := NewHandler(discardHandler{}, &HandlerOptions{Notifiers: newBenchmarkNotifiers(b)})
+
+	tests := []struct {
+		name    string
+		handler slog.Handler
+	}{
+		{name: "ErrorNotify", handler: h},
+		{name: "ErrorNotifyWithGroup", handler: h.WithAttrs([]slog.Attr{slog.String("with1", "arg0")}).WithGroup("group1")},
+	}
+
+	for _, tc := range tests {
+		b.Run(tc.name, func(b *testing.B) {
+			h := tc.handler.(*Handler)
+			r := slog.NewRecord(defaultTime, slog.LevelError, "main message", 0)
+			r.AddAttrs(slog.Any("err", err), slog.String("str", "foo"), slog.Int("int", 1))
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = h.next.Handle(ctx, r)
+				bug := bugRecord{h: h, ctx: ctx, record: r.Clone()}
+				if !h.errorHasStack(r) {
+					bug.stack = callers()
+				}
+				_ = h.logToBug(bug)
+			}
+		})
+	}
+}
+
+// BenchmarkLogToBug measures the work the NotifierWorkers do to build a bug,
+// which used to be done on the logging goroutine.
+func BenchmarkLogToBug(b *testing.B) {
+	h := NewHandler(discardHandler{}, &HandlerOptions{Notifiers: newBenchmarkNotifiers(b)})
+	h2 := h.WithAttrs([]slog.Attr{slog.String("with1", "arg0")}).WithGroup("group1").(*Handler)
+
+	r := slog.NewRecord(defaultTime, slog.LevelError, "main message", 0)
+	r.AddAttrs(slog.Any("err", errors.New("terrible error")), slog.Int("int", 1), slog.String("str", "foo"))
+	bug := bugRecord{h: h2, ctx: context.Background(), record: r, stack: callers()}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = h2.logToBug(bug)
+	}
+}
@@ -0,0 +1,281 @@
+package slogbugsnag
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// breadcrumbsTab is the bugsnag metadata tab that breadcrumbs go in
+const breadcrumbsTab = "breadcrumbs"
+
+// breadcrumbMaxValueLength is the maximum length in bytes of each breadcrumb
+// attribute value, keeping breadcrumbs compact
+const breadcrumbMaxValueLength = 256
+
+// BreadcrumbOptions are options for recording breadcrumbs: recent log records
+// below the NotifyLevel, which are attached to each bug sent to bugsnag, to
+// show what happened just before it.
+// The breadcrumbs are sent in a "breadcrumbs" metadata tab, oldest first,
+// each with its time, level, message, and a compact set of attributes.
+type BreadcrumbOptions struct {
+	// Scope decides which records are kept together and attached to a bug.
+	// Defaults to BreadcrumbsPerProcess.
+	Scope BreadcrumbScope
+
+	// Capacity is the maximum number of breadcrumbs kept in each scope.
+	// Older breadcrumbs are dropped once it is full. Defaults to 25.
+	Capacity int
+
+	// MinLevel is the minimum level of records kept as breadcrumbs.
+	// Records at or above the NotifyLevel are sent as bugs, not breadcrumbs.
+	// Records below the level the next handler is enabled for are still kept.
+	// Defaults to slog.LevelInfo.
+	MinLevel slog.Leveler
+
+	// MaxAttrs is the maximum number of attributes kept for each breadcrumb.
+	// Attributes in groups are flattened, with their keys joined by dots.
+	// Defaults to 10.
+	MaxAttrs int
+}
+
+// BreadcrumbScope decides which records are kept together as breadcrumbs
+type BreadcrumbScope int
+
+const (
+	// BreadcrumbsPerProcess keeps one ring buffer of breadcrumbs for the
+	// handler and all handlers derived from it with WithAttrs and WithGroup.
+	BreadcrumbsPerProcess BreadcrumbScope = iota
+
+	// BreadcrumbsPerContext keeps a ring buffer of breadcrumbs in each context
+	// returned by [ContextWithBreadcrumbs], such as one per request or job.
+	// Records logged with a context that has no buffer are not kept, and bugs
+	// only get the breadcrumbs of the context they are logged with.
+	BreadcrumbsPerContext
+)
+
+// breadcrumbsCtxKey is the context key for a breadcrumbBuffer
+type breadcrumbsCtxKey struct{}
+
+// ContextWithBreadcrumbs returns a copy of the context with a new, empty
+// ring buffer of breadcrumbs, for handlers using BreadcrumbsPerContext.
+// Records logged with the returned context (or its children) are kept in it,
+// and are attached to bugs logged with the same context.
+func ContextWithBreadcrumbs(ctx context.Context) context.Context {
+	return context.WithValue(ctx, breadcrumbsCtxKey{}, &breadcrumbBuffer{})
+}
+
+// breadcrumb is a log record kept as a breadcrumb, along with the handler that
+// received it, whose groups and attributes it is formatted with
+type breadcrumb struct {
+	h      *Handler
+	record slog.Record
+}
+
+// breadcrumbBuffer is a ring buffer of breadcrumbs, safe for concurrent use
+type breadcrumbBuffer struct {
+	mu       sync.Mutex
+	crumbs   []breadcrumb
+	next     int
+	full     bool
+	released bool
+}
+
+// add puts the breadcrumb in the buffer, replacing the oldest one if full.
+// The buffer is allocated on first use, with the given capacity.
+func (bb *breadcrumbBuffer) add(crumb breadcrumb, capacity int) {
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	if bb.released {
+		return
+	}
+	if bb.crumbs == nil {
+		bb.crumbs = make([]breadcrumb, capacity)
+	}
+	bb.crumbs[bb.next] = crumb
+	bb.next++
+	if bb.next == len(bb.crumbs) {
+		bb.next = 0
+		bb.full = true
+	}
+}
+
+// release drops all breadcrumbs in the buffer, and stops it from keeping more
+func (bb *breadcrumbBuffer) release() {
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	bb.crumbs, bb.next, bb.full, bb.released = nil, 0, false, true
+}
+
+// snapshot returns a copy of the breadcrumbs in the buffer, oldest first
+func (bb *breadcrumbBuffer) snapshot() []breadcrumb {
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	if !bb.full {
+		return append([]breadcrumb(nil), bb.crumbs[:bb.next]...)
+	}
+	crumbs := make([]breadcrumb, 0, len(bb.crumbs))
+	crumbs = append(crumbs, bb.crumbs[bb.next:]...)
+	return append(crumbs, bb.crumbs[:bb.next]...)
+}
+
+// breadcrumbs applies a handler's breadcrumb options
+type breadcrumbs struct {
+	scope    BreadcrumbScope
+	capacity int
+	minLevel slog.Leveler
+	maxAttrs int
+	process  *breadcrumbBuffer
+}
+
+// newBreadcrumbs returns breadcrumbs for the options, or nil if there are no options
+func newBreadcrumbs(opts *BreadcrumbOptions) *breadcrumbs {
+	if opts == nil {
+		return nil
+	}
+	bc := &breadcrumbs{
+		scope:    opts.Scope,
+		capacity: opts.Capacity,
+		minLevel: opts.MinLevel,
+		maxAttrs: opts.MaxAttrs,
+	}
+	if bc.capacity < 1 {
+		bc.capacity = 25
+	}
+	if bc.minLevel == nil {
+		bc.minLevel = slog.LevelInfo
+	}
+	if bc.maxAttrs < 1 {
+		bc.maxAttrs = 10
+	}
+	if bc.scope == BreadcrumbsPerProcess {
+		bc.process = &breadcrumbBuffer{}
+	}
+	return bc
+}
+
+// keeps returns true if records at the level are kept as breadcrumbs
+func (bc *breadcrumbs) keeps(level slog.Level) bool {
+	return bc != nil && level >= bc.minLevel.Level()
+}
+
+// buffer returns the ring buffer for the scope, or nil if there is none
+func (bc *breadcrumbs) buffer(ctx context.Context) *breadcrumbBuffer {
+	if bc == nil {
+		return nil
+	}
+	if bc.scope == BreadcrumbsPerContext {
+		if ctx == nil {
+			return nil
+		}
+		bb, _ := ctx.Value(breadcrumbsCtxKey{}).(*breadcrumbBuffer)
+		return bb
+	}
+	return bc.process
+}
+
+// add keeps the record as a breadcrumb, if the scope has a buffer
+func (bc *breadcrumbs) add(ctx context.Context, h *Handler, r slog.Record) {
+	if bb := bc.buffer(ctx); bb != nil {
+		bb.add(breadcrumb{h: h, record: r.Clone()}, bc.capacity)
+	}
+}
+
+// snapshot returns a copy of the scope's breadcrumbs, oldest first
+func (bc *breadcrumbs) snapshot(ctx context.Context) []breadcrumb {
+	if bb := bc.buffer(ctx); bb != nil {
+		return bb.snapshot()
+	}
+	return nil
+}
+
+// addBreadcrumbs adds the breadcrumbs to their own tab, keyed by their
+// zero-padded position, so that they sort oldest first.
+// If compact, each breadcrumb keeps only the first MaxAttrs attributes, and
+// its string values are kept short.
+func (b *metaDataBuilder) addBreadcrumbs(tab string, crumbs []breadcrumb, compact bool) {
+	if len(crumbs) == 0 {
+		return
+	}
+	var maxAttrs int
+	if compact {
+		maxAttrs = 10
+		if b.h.breadcrumbs != nil {
+			maxAttrs = b.h.breadcrumbs.maxAttrs
+		}
+	}
+
+	width := len(fmt.Sprint(len(crumbs) - 1))
+	for i, crumb := range crumbs {
+		r := crumb.record
+		entry := map[string]any{
+			"time":  r.Time.Format(time.RFC3339Nano),
+			"level": r.Level.String(),
+			"msg":   b.sanitizeString(r.Message),
+		}
+		attrs := map[string]any{}
+		b.flattenAttrs(attrs, "", nil, crumb.h.collectAttrs(r), maxAttrs)
+		if len(attrs) > 0 {
+			entry["attrs"] = attrs
+		}
+		b.md.Add(tab, fmt.Sprintf("%0*d", width, i), entry)
+	}
+}
+
+// flattenAttrs flattens the attributes into the map, with the keys of grouped
+// attributes joined by dots. Keys are redacted.
+// If maxAttrs is positive, attributes are added until the map holds maxAttrs
+// of them, and string values are kept short.
+func (b *metaDataBuilder) flattenAttrs(m map[string]any, prefix string, groups []string, attrs []slog.Attr, maxAttrs int) {
+	for _, attr := range attrs {
+		if maxAttrs > 0 && len(m) >= maxAttrs {
+			return
+		}
+
+		attr.Value = attr.Value.Resolve()
+		if b.h.replaceAttr != nil && attr.Value.Kind() != slog.KindGroup {
+			attr = b.h.replaceAttr(groups, attr)
+			attr.Value = attr.Value.Resolve()
+			if attr.Equal(slog.Attr{}) {
+				continue
+			}
+		}
+
+		key := prefix + attr.Key
+		if b.redaction.redactKey(attr.Key, b.filters) {
+			m[key] = b.redaction.replacement
+			continue
+		}
+
+		if attr.Value.Kind() == slog.KindGroup {
+			if attr.Key == "" {
+				b.flattenAttrs(m, prefix, groups, attr.Value.Group(), maxAttrs)
+			} else {
+				b.flattenAttrs(m, key+".", append(groups[:len(groups):len(groups)], attr.Key), attr.Value.Group(), maxAttrs)
+			}
+			continue
+		}
+
+		value := b.sanitizeAttrValue(attr.Value)
+		if s, ok := value.(string); ok && maxAttrs > 0 {
+			value = compactString(s)
+		}
+		m[key] = value
+	}
+}
+
+// compactString shortens the string to breadcrumbMaxValueLength, on a rune
+// boundary, and marks how much was removed
+func compactString(s string) string {
+	if len(s) <= breadcrumbMaxValueLength {
+		return s
+	}
+	cut := breadcrumbMaxValueLength
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + truncatedMarker(len(s)-cut)
+}
@@ -0,0 +1,147 @@
+package slogbugsnag
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+)
+
+func TestBreadcrumbs(t *testing.T) {
+	t.Parallel()
+
+	svr := newBugsnagTestServer(t)
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 1})
+
+	// The next handler only handles warnings, but debug records are still kept
+	buf := &bytes.Buffer{}
+	logger := slog.New(NewHandler(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelWarn}), &HandlerOptions{
+		Notifiers: notifiers,
+		Breadcrumbs: &BreadcrumbOptions{
+			Capacity: 3,
+			MinLevel: slog.LevelDebug,
+			MaxAttrs: 2,
+		},
+	}))
+
+	logger.Debug("dropped, capacity full")
+	logger.Debug("debug", "id", 1)
+	logger.With("with", "arg").WithGroup("g").Info("info", "password", "abc123", "dropped", "max attrs")
+	logger.Warn("warn", "long", strings.Repeat("a", 300))
+	logger.Error("error")
+
+	notifiers.Close()
+
+	if strings.Contains(buf.String(), "debug") || !strings.Contains(buf.String(), "warn") {
+		t.Error("Expected only warn and error to be passed to the next handler; Got:", buf.String())
+	}
+
+	events := svr.Events()
+	if len(events) != 1 {
+		t.Fatal("Expected 1 bugsnag event; Got:", len(events))
+	}
+
+	crumbs := events[0].MetaData["breadcrumbs"]
+	if len(crumbs) != 3 {
+		t.Fatalf("Expected 3 breadcrumbs; Got: %#+v\n", crumbs)
+	}
+	expected := map[string]any{
+		"0": map[string]any{"level": "DEBUG", "msg": "debug", "attrs": map[string]any{"id": float64(1)}},
+		"1": map[string]any{"level": "INFO", "msg": "info", "attrs": map[string]any{"with": "arg", "g.password": "[FILTERED]"}},
+		"2": map[string]any{"level": "WARN", "msg": "warn", "attrs": map[string]any{"long": strings.Repeat("a", 256) + "…[truncated 44B]"}},
+	}
+	for key, crumb := range crumbs {
+		m, _ := crumb.(map[string]any)
+		if _, ok := m["time"].(string); !ok {
+			t.Errorf("Expected breadcrumb %s to have a time; Got: %#+v\n", key, m)
+		}
+		delete(m, "time")
+		if !reflect.DeepEqual(m, expected[key]) {
+			t.Errorf("Breadcrumb %s:\nExpected: %#+v\nGot: %#+v\n", key, expected[key], m)
+		}
+	}
+}
+
+func TestBreadcrumbsPerContext(t *testing.T) {
+	t.Parallel()
+
+	svr := newBugsnagTestServer(t)
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 1})
+	logger := slog.New(NewHandler(&testHandler{}, &HandlerOptions{
+		Notifiers:   notifiers,
+		Breadcrumbs: &BreadcrumbOptions{Scope: BreadcrumbsPerContext},
+	}))
+
+	ctx1 := ContextWithBreadcrumbs(context.Background())
+	ctx2 := ContextWithBreadcrumbs(context.Background())
+
+	logger.DebugContext(ctx1, "below min level")
+	logger.InfoContext(ctx1, "first request")
+	logger.InfoContext(ctx2, "second request")
+	logger.Info("no scope")
+	logger.ErrorContext(ctx1, "error1", "breadcrumbs", "user group")
+	logger.ErrorContext(context.Background(), "error2")
+
+	notifiers.Close()
+
+	events := svr.Events()
+	if len(events) != 2 {
+		t.Fatal("Expected 2 bugsnag events; Got:", len(events))
+	}
+
+	for _, event := range events {
+		switch event.Context {
+		case "error1":
+			crumbs := event.MetaData["breadcrumbs"]
+			if len(crumbs) != 1 || crumbs["0"].(map[string]any)["msg"] != "first request" {
+				t.Errorf("%#+v\n", crumbs)
+			}
+			if event.MetaData["log"]["breadcrumbs"] != "user group" {
+				t.Errorf("%#+v\n", event.MetaData["log"])
+			}
+		case "error2":
+			if _, ok := event.MetaData["breadcrumbs"]; ok {
+				t.Errorf("Expected no breadcrumbs; Got: %#+v\n", event.MetaData["breadcrumbs"])
+			}
+		default:
+			t.Error("Unexpected event:", event.Context)
+		}
+	}
+}
+
+func TestFlattenAttrsReplaceAttr(t *testing.T) {
+	t.Parallel()
+
+	// ReplaceAttr has the same contract as for the bug's metadata:
+	// only zero attributes are dropped
+	h := &Handler{
+		notifiers: &NotifierWorkers{notifier: bugsnag.New()},
+		replaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			switch a.Key {
+			case "drop":
+				return slog.Attr{}
+			case "blank":
+				a.Key = ""
+			case "inline":
+				return slog.Group("", slog.String("inlined", a.Value.String()))
+			}
+			return a
+		},
+	}
+	b := h.newMetaDataBuilder()
+	attrs := map[string]any{}
+	b.flattenAttrs(attrs, "", nil, []slog.Attr{
+		slog.String("drop", "dropped"),
+		slog.Group("g", slog.String("blank", "kept"), slog.String("drop", "dropped")),
+		slog.String("inline", "foo"),
+	}, 0)
+
+	expected := map[string]any{"g.": "kept", "inlined": "foo"}
+	if !reflect.DeepEqual(attrs, expected) {
+		t.Errorf("%#+v\n", attrs)
+	}
+}
@@ -0,0 +1,231 @@
+// Package bugsnaggrpc provides gRPC server interceptors that report failing
+// calls and panics to bugsnag, through a slog logger whose handler is, or
+// leads to, a [slogbugsnag.Handler].
+package bugsnaggrpc
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	slogbugsnag "github.com/veqryn/slog-bugsnag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// grpcGroup is the group that call info is logged in, which becomes the
+// "grpc" bugsnag metadata tab
+const grpcGroup = "grpc"
+
+// credentialKeys are incoming metadata keys that hold credentials, which are
+// left out of the CallInfo
+var credentialKeys = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"x-api-key":           true,
+}
+
+// Options are options for the interceptors
+type Options struct {
+	// Logger logs the failing calls and panics. Its handler should be a
+	// slogbugsnag.Handler, or lead to one, so that they are sent to bugsnag.
+	// Add [ContextExtractor] to the handler's ContextExtractors, for the
+	// bugs to include the call info.
+	// If nil, slog.Default() is used.
+	Logger *slog.Logger
+
+	// CodeToLevel decides the level that calls failing with each status code
+	// are logged at. Only levels at or above the handler's NotifyLevel are
+	// sent to bugsnag. Calls that return codes.OK are never logged.
+	// If nil, DefaultCodeToLevel is used.
+	CodeToLevel func(code codes.Code) slog.Level
+
+	// Sessions starts a bugsnag session for each call.
+	// If nil, bugsnag's global session tracker is used, if the bugsnag config
+	// AutoCaptureSessions is on.
+	Sessions *slogbugsnag.SessionTracker
+}
+
+// DefaultCodeToLevel logs client mistakes at Info, failures that may be
+// expected at Warn, and server failures at Error.
+func DefaultCodeToLevel(code codes.Code) slog.Level {
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.Unauthenticated:
+		return slog.LevelInfo
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted, codes.FailedPrecondition, codes.Aborted, codes.OutOfRange:
+		return slog.LevelWarn
+	default:
+		// Unknown, Unimplemented, Internal, Unavailable, DataLoss
+		return slog.LevelError
+	}
+}
+
+// CallInfo describes the gRPC call being served. It is attached to the
+// context by the interceptors, and is only sent to bugsnag, as the "grpc"
+// group, by the handler's [ContextExtractor]. It is not added to the logger,
+// so the application's own logs don't receive the incoming metadata.
+type CallInfo struct {
+	// Method is the full method name (ex: "/package.Service/Method")
+	Method string
+
+	// Peer is the address of the client
+	Peer string
+
+	// Metadata is the incoming metadata. Binary ("-bin") keys, and keys that
+	// hold credentials (such as "authorization" and "cookie"), are left out.
+	Metadata metadata.MD
+
+	// Stream is true if the call is a stream, rather than unary
+	Stream bool
+}
+
+// LogValue returns the call info as a group
+func (ci *CallInfo) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.String("method", ci.Method)}
+	if ci.Peer != "" {
+		attrs = append(attrs, slog.String("peer", ci.Peer))
+	}
+	if len(ci.Metadata) > 0 {
+		md := make(map[string]any, len(ci.Metadata))
+		for k, v := range ci.Metadata {
+			if len(v) == 1 {
+				md[k] = v[0]
+			} else {
+				md[k] = v
+			}
+		}
+		attrs = append(attrs, slog.Any("metadata", md))
+	}
+	attrs = append(attrs, slog.Bool("stream", ci.Stream))
+	return slog.GroupValue(attrs...)
+}
+
+// callInfoCtxKey is the context key for the CallInfo
+type callInfoCtxKey struct{}
+
+// CallInfoFromContext returns the info of the call being served, if the
+// context came from one of the interceptors
+func CallInfoFromContext(ctx context.Context) (*CallInfo, bool) {
+	ci, ok := ctx.Value(callInfoCtxKey{}).(*CallInfo)
+	return ci, ok
+}
+
+var _ slogbugsnag.ContextExtractor = ContextExtractor // Validate implements type
+
+// ContextExtractor is a [slogbugsnag.ContextExtractor] that puts the CallInfo
+// in the "grpc" metadata tab of every bug logged with the call's context,
+// not just the ones logged by the interceptors.
+func ContextExtractor(ctx context.Context) []slog.Attr {
+	if ci, ok := CallInfoFromContext(ctx); ok {
+		return []slog.Attr{slog.Any(grpcGroup, ci)}
+	}
+	return nil
+}
+
+// UnaryServerInterceptor returns a server interceptor for unary calls, which
+// starts a bugsnag session, attaches the CallInfo to the context (and the
+// method as the bugsnag context of any bugs logged with it), recovers
+// and logs panics as unhandled bugs, and logs calls that fail.
+// If opts is nil, the default options are used.
+func UnaryServerInterceptor(opts *Options) grpc.UnaryServerInterceptor {
+	opts = defaultOptions(opts)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		ctx = newCallContext(ctx, opts.Sessions, info.FullMethod, false)
+		err = serve(ctx, opts, func() error {
+			var handlerErr error
+			resp, handlerErr = handler(ctx, req)
+			return handlerErr
+		})
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a server interceptor for streams, which
+// starts a bugsnag session, attaches the CallInfo to the stream's context (and
+// the method as the bugsnag context of any bugs logged with it), recovers and logs panics as unhandled bugs, and logs streams that fail.
+// If opts is nil, the default options are used.
+func StreamServerInterceptor(opts *Options) grpc.StreamServerInterceptor {
+	opts = defaultOptions(opts)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := newCallContext(ss.Context(), opts.Sessions, info.FullMethod, true)
+		return serve(ctx, opts, func() error {
+			return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		})
+	}
+}
+
+// defaultOptions returns a copy of the options, with the defaults filled in
+func defaultOptions(opts *Options) *Options {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.CodeToLevel == nil {
+		o.CodeToLevel = DefaultCodeToLevel
+	}
+	return &o
+}
+
+// newCallContext starts a bugsnag session, and attaches the call info to the
+// context, with the method as the bugsnag context of any bugs
+func newCallContext(ctx context.Context, sessions *slogbugsnag.SessionTracker, method string, stream bool) context.Context {
+	ci := &CallInfo{Method: method, Stream: stream}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ci.Peer = p.Addr.String()
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ci.Metadata = metadata.MD{}
+		for k, v := range md {
+			if !strings.HasSuffix(k, "-bin") && !credentialKeys[k] {
+				ci.Metadata[k] = v
+			}
+		}
+	}
+
+	ctx = sessions.StartSession(ctx)
+	ctx = slogbugsnag.ContextWithBugsnagContext(ctx, method)
+	return context.WithValue(ctx, callInfoCtxKey{}, ci)
+}
+
+// serve runs the call, logging it if it panics or fails.
+// Panics are returned as codes.Internal errors.
+func serve(ctx context.Context, opts *Options, call func() error) error {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	var err error
+	panicked := true
+	func() {
+		defer slogbugsnag.Recover(ctx, logger)
+		err = call()
+		panicked = false
+	}()
+	if panicked {
+		return status.Error(codes.Internal, "panic")
+	}
+	if err == nil {
+		return nil
+	}
+
+	code := status.Code(err)
+	logger.LogAttrs(ctx, opts.CodeToLevel(code), "grpc call failed",
+		slog.Any("err", err), slog.String("code", code.String()))
+	return err
+}
+
+// serverStream overrides the context of a grpc.ServerStream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the stream's context, with the CallInfo attached
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
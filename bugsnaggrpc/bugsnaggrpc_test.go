@@ -0,0 +1,212 @@
+package bugsnaggrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+	slogbugsnag "github.com/veqryn/slog-bugsnag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type bugsnagEvent struct {
+	Context    string `json:"context"`
+	Exceptions []struct {
+		ErrorClass string `json:"errorClass"`
+		Message    string `json:"message"`
+	} `json:"exceptions"`
+	MetaData  map[string]map[string]any `json:"metaData"`
+	Unhandled bool                      `json:"unhandled"`
+}
+
+// newBugsnagTestServer starts a fake bugsnag server, and returns a notifier
+// that sends to it, and a function returning all events received so far
+func newBugsnagTestServer(t *testing.T) (*bugsnag.Notifier, func() []bugsnagEvent) {
+	var mu sync.Mutex
+	var events []bugsnagEvent
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		var payload struct {
+			Events []bugsnagEvent `json:"events"`
+		}
+		if err := json.Unmarshal(b, &payload); err != nil {
+			t.Error("Unable to unmarshal json to bugsnag payload")
+		}
+		mu.Lock()
+		events = append(events, payload.Events...)
+		mu.Unlock()
+	}))
+	t.Cleanup(svr.Close)
+
+	notifier := bugsnag.New(bugsnag.Configuration{
+		APIKey:    "1234567890abcdef1234567890abcdef",
+		Endpoints: bugsnag.Endpoints{Notify: svr.URL, Sessions: svr.URL},
+	})
+	return notifier, func() []bugsnagEvent {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]bugsnagEvent(nil), events...)
+	}
+}
+
+// healthServer fails, panics, or succeeds, depending on the service name
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if _, ok := CallInfoFromContext(ctx); !ok {
+		return nil, status.Error(codes.DataLoss, "missing call info")
+	}
+	switch req.Service {
+	case "panic":
+		panic("oh no")
+	case "internal":
+		return nil, status.Error(codes.Internal, "broken")
+	case "notfound":
+		return nil, status.Error(codes.NotFound, "no such service")
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func (healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	if _, ok := CallInfoFromContext(stream.Context()); !ok {
+		return status.Error(codes.DataLoss, "missing call info")
+	}
+	if req.Service == "panic" {
+		panic("stream oh no")
+	}
+	return status.Error(codes.Unavailable, "try later")
+}
+
+func TestInterceptors(t *testing.T) {
+	t.Parallel()
+
+	notifier, events := newBugsnagTestServer(t)
+	notifiers := slogbugsnag.NewNotifierWorkers(&slogbugsnag.NotifierOptions{Notifier: notifier, MaxNotifierConcurrency: 1})
+	var logs bytes.Buffer
+	logger := slog.New(slogbugsnag.NewHandler(slog.NewTextHandler(&logs, nil), &slogbugsnag.HandlerOptions{
+		Notifiers:         notifiers,
+		ContextExtractors: []slogbugsnag.ContextExtractor{ContextExtractor},
+	}))
+
+	opts := &Options{Logger: logger}
+	lis := bufconn.Listen(1024 * 1024)
+	svr := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(opts)),
+		grpc.StreamInterceptor(StreamServerInterceptor(opts)),
+	)
+	healthpb.RegisterHealthServer(svr, healthServer{})
+	go func() { _ = svr.Serve(lis) }()
+	defer svr.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "abc", "authorization", "Bearer secret")
+	for service, expected := range map[string]codes.Code{"": codes.OK, "panic": codes.Internal, "internal": codes.Internal, "notfound": codes.NotFound} {
+		if _, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: service}); status.Code(err) != expected {
+			t.Errorf("Expected %q to return %s; Got: %v", service, expected, err)
+		}
+	}
+	for service, expected := range map[string]codes.Code{"panic": codes.Internal, "": codes.Unavailable} {
+		stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = stream.Recv(); status.Code(err) != expected {
+			t.Errorf("Expected stream %q to return %s; Got: %v", service, expected, err)
+		}
+	}
+
+	notifiers.Close()
+
+	// The call info only goes to bugsnag, not to the application's logs
+	if strings.Contains(logs.String(), "x-request-id") || strings.Contains(logs.String(), "secret") {
+		t.Error("Expected no call metadata in the logs; Got:", logs.String())
+	}
+
+	// NotFound is logged at Info, so it is not sent to bugsnag
+	got := map[string]bugsnagEvent{}
+	for _, event := range events() {
+		if event.Context != event.MetaData["grpc"]["method"] {
+			t.Errorf("Expected the method as the bugsnag context; Got: %q", event.Context)
+		}
+		got[event.MetaData["log"]["msg"].(string)+" "+event.Context] = event
+	}
+	if len(got) != 4 {
+		t.Fatalf("Expected 4 bugsnag events; Got: %#+v\n", got)
+	}
+
+	for key, unhandled := range map[string]bool{
+		"panic: oh no /grpc.health.v1.Health/Check":        true,
+		"grpc call failed /grpc.health.v1.Health/Check":    false,
+		"panic: stream oh no /grpc.health.v1.Health/Watch": true,
+		"grpc call failed /grpc.health.v1.Health/Watch":    false,
+	} {
+		event, ok := got[key]
+		if !ok {
+			t.Errorf("Missing event %q", key)
+			continue
+		}
+		if event.Unhandled != unhandled {
+			t.Errorf("Expected %q unhandled to be %t", key, unhandled)
+		}
+		grpcTab := event.MetaData["grpc"]
+		md, _ := grpcTab["metadata"].(map[string]any)
+		if grpcTab["peer"] != "bufconn" || md["x-request-id"] != "abc" || md["authorization"] != nil {
+			t.Errorf("%q: %#+v\n", key, grpcTab)
+		}
+	}
+}
+
+func TestDefaultCodeToLevel(t *testing.T) {
+	t.Parallel()
+
+	for code, expected := range map[codes.Code]slog.Level{
+		codes.OK:               slog.LevelInfo,
+		codes.NotFound:         slog.LevelInfo,
+		codes.DeadlineExceeded: slog.LevelWarn,
+		codes.Internal:         slog.LevelError,
+		codes.Unknown:          slog.LevelError,
+	} {
+		if got := DefaultCodeToLevel(code); got != expected {
+			t.Errorf("%s: Expected %s; Got: %s", code, expected, got)
+		}
+	}
+}
+
+func TestContextExtractor(t *testing.T) {
+	t.Parallel()
+
+	if attrs := ContextExtractor(context.Background()); len(attrs) != 0 {
+		t.Error("Expected no attributes; Got:", attrs)
+	}
+
+	ctx := newCallContext(context.Background(), nil, "/pkg.Service/Method", false)
+	attrs := ContextExtractor(ctx)
+	if len(attrs) != 1 || attrs[0].Key != "grpc" || attrs[0].Value.Resolve().Group()[0].Value.String() != "/pkg.Service/Method" {
+		t.Errorf("%#+v\n", attrs)
+	}
+}
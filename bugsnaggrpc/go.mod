@@ -0,0 +1,23 @@
+module github.com/veqryn/slog-bugsnag/bugsnaggrpc
+
+go 1.25.0
+
+replace github.com/veqryn/slog-bugsnag => ../
+
+require (
+	github.com/bugsnag/bugsnag-go/v2 v2.5.1
+	github.com/veqryn/slog-bugsnag v0.0.0-00010101000000-000000000000
+	google.golang.org/grpc v1.84.0
+)
+
+require (
+	github.com/bugsnag/panicwrap v1.3.4 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/kardianos/osext v0.0.0-20190222173326-2bc1f35cddc0 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	golang.org/x/net v0.57.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+	golang.org/x/text v0.40.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260706201446-f0a921348800 // indirect
+	google.golang.org/protobuf v1.36.11 // indirect
+)
@@ -0,0 +1,135 @@
+// Package bugsnagotel correlates bugs sent to bugsnag by a
+// [slogbugsnag.Handler] with OpenTelemetry traces: it puts the trace and span
+// of the log call's context in a "trace" metadata tab, falls back to the span's
+// name for the bug's context, and can record each bug as an exception event on the span.
+package bugsnagotel
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	slogbugsnag "github.com/veqryn/slog-bugsnag"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceGroup is the group the trace is logged in, which becomes the "trace"
+// bugsnag metadata tab
+const traceGroup = "trace"
+
+// Options are options for a Correlator
+type Options struct {
+	// LinkTemplate is a link to the trace in your tracing UI, which is added
+	// to the trace tab. The placeholders "{traceId}" and "{spanId}" are
+	// replaced with the IDs (ex: "https://jaeger.example.com/trace/{traceId}").
+	// If empty, no link is added.
+	LinkTemplate string
+
+	// DisableContext, if true, stops the bug's context (its title in bugsnag)
+	// from being set to the span's name. The span's name is only used in place
+	// of the log message: a [slogbugsnag.Context] attribute, the context from
+	// [slogbugsnag.ContextWithBugsnagContext], or one picked by the handler's
+	// BugsnagContext callback, all take precedence over it.
+	DisableContext bool
+
+	// RecordSpanEvents, if true, records each bug as an exception event on
+	// the span, with the log message and the error.
+	// The message and error are recorded as they were logged, before the
+	// handler's RedactOptions are applied, so any secrets or personally
+	// identifiable information in them are sent to the tracing backend.
+	RecordSpanEvents bool
+}
+
+// Correlator correlates bugs with the OpenTelemetry trace in their context.
+// Use Apply to add it to the handler options.
+type Correlator struct {
+	opts Options
+}
+
+// New returns a Correlator. If opts is nil, the default options are used.
+func New(opts *Options) *Correlator {
+	c := &Correlator{}
+	if opts != nil {
+		c.opts = *opts
+	}
+	return c
+}
+
+// Apply adds the Correlator's ContextExtractor and OnNotify to the handler
+// options, and its BugsnagContext, after any BugsnagContext callback already set.
+func (c *Correlator) Apply(opts *slogbugsnag.HandlerOptions) {
+	opts.ContextExtractors = append(opts.ContextExtractors, c.ContextExtractor)
+	opts.OnNotify = append(opts.OnNotify, c.OnNotify)
+	if c.opts.DisableContext {
+		return
+	}
+	if prev := opts.BugsnagContext; prev != nil {
+		opts.BugsnagContext = func(ctx context.Context, r slog.Record) string {
+			if s := prev(ctx, r); s != "" {
+				return s
+			}
+			return c.BugsnagContext(ctx, r)
+		}
+		return
+	}
+	opts.BugsnagContext = c.BugsnagContext
+}
+
+var _ slogbugsnag.ContextExtractor = (&Correlator{}).ContextExtractor // Validate implements type
+
+// ContextExtractor is a [slogbugsnag.ContextExtractor] that puts the trace ID,
+// span ID, sampled flag, and link of the context's span in the "trace" tab
+func (c *Correlator) ContextExtractor(ctx context.Context) []slog.Attr {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	traceID, spanID := sc.TraceID().String(), sc.SpanID().String()
+	attrs := []any{
+		slog.String("traceId", traceID),
+		slog.String("spanId", spanID),
+		slog.Bool("sampled", sc.IsSampled()),
+	}
+	if c.opts.LinkTemplate != "" {
+		link := strings.NewReplacer("{traceId}", traceID, "{spanId}", spanID).Replace(c.opts.LinkTemplate)
+		attrs = append(attrs, slog.String("link", link))
+	}
+	return []slog.Attr{slog.Group(traceGroup, attrs...)}
+}
+
+// OnNotify records the bug as an exception event on the context's span, if
+// RecordSpanEvents is on and the span is recording. It must be called on the
+// logging goroutine, before the span could have ended.
+// Nothing is redacted: see RecordSpanEvents.
+func (c *Correlator) OnNotify(ctx context.Context, r slog.Record, err error) {
+	if !c.opts.RecordSpanEvents {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	if err == nil {
+		err = errors.New(r.Message)
+	}
+	span.RecordError(err, trace.WithTimestamp(r.Time), trace.WithAttributes(
+		attribute.String("log.message", r.Message),
+		attribute.String("log.level", r.Level.String()),
+		attribute.Bool("bugsnag.notified", true),
+	))
+}
+
+// BugsnagContext is a HandlerOptions.BugsnagContext callback that returns the
+// name of the span in the log call's context, if the span has a name (as spans
+// from the OpenTelemetry SDK do). The log message is still in the log tab.
+func (c *Correlator) BugsnagContext(ctx context.Context, _ slog.Record) string {
+	if ctx == nil {
+		return ""
+	}
+	if named, ok := trace.SpanFromContext(ctx).(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return ""
+}
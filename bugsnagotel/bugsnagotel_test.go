@@ -0,0 +1,152 @@
+package bugsnagotel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+	slogbugsnag "github.com/veqryn/slog-bugsnag"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type bugsnagEvent struct {
+	Context  string                    `json:"context"`
+	MetaData map[string]map[string]any `json:"metaData"`
+}
+
+// newBugsnagTestServer starts a fake bugsnag server, and returns a notifier
+// that sends to it, and a function returning all events received so far
+func newBugsnagTestServer(t *testing.T) (*bugsnag.Notifier, func() []bugsnagEvent) {
+	var mu sync.Mutex
+	var events []bugsnagEvent
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		var payload struct {
+			Events []bugsnagEvent `json:"events"`
+		}
+		if err := json.Unmarshal(b, &payload); err != nil {
+			t.Error("Unable to unmarshal json to bugsnag payload")
+		}
+		mu.Lock()
+		events = append(events, payload.Events...)
+		mu.Unlock()
+	}))
+	t.Cleanup(svr.Close)
+
+	notifier := bugsnag.New(bugsnag.Configuration{
+		APIKey:    "1234567890abcdef1234567890abcdef",
+		Endpoints: bugsnag.Endpoints{Notify: svr.URL, Sessions: svr.URL},
+	})
+	return notifier, func() []bugsnagEvent {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]bugsnagEvent(nil), events...)
+	}
+}
+
+func TestCorrelator(t *testing.T) {
+	t.Parallel()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+
+	notifier, events := newBugsnagTestServer(t)
+	notifiers := slogbugsnag.NewNotifierWorkers(&slogbugsnag.NotifierOptions{Notifier: notifier, MaxNotifierConcurrency: 1})
+	opts := &slogbugsnag.HandlerOptions{Notifiers: notifiers}
+	New(&Options{LinkTemplate: "https://traces.example.com/{traceId}?span={spanId}", RecordSpanEvents: true}).Apply(opts)
+	logger := slog.New(slogbugsnag.NewHandler(slog.NewTextHandler(io.Discard, nil), opts))
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "GET /users/{id}")
+	sc := span.SpanContext()
+	logger.InfoContext(ctx, "not notified")
+	logger.ErrorContext(ctx, "unable to load user", "err", errors.New("terrible error"))
+	logger.ErrorContext(ctx, "explicit", "route", slogbugsnag.Context("GET /explicit"))
+	logger.ErrorContext(slogbugsnag.ContextWithBugsnagContext(ctx, "cleanup-job"), "from context")
+	span.End()
+
+	// No span in the context
+	logger.Error("no span")
+
+	notifiers.Close()
+
+	got := events()
+	if len(got) != 4 {
+		t.Fatal("Expected 4 bugsnag events; Got:", len(got))
+	}
+	for _, event := range got {
+		switch event.Context {
+		case "GET /users/{id}":
+			traceTab := event.MetaData["trace"]
+			expectedLink := "https://traces.example.com/" + sc.TraceID().String() + "?span=" + sc.SpanID().String()
+			if traceTab["traceId"] != sc.TraceID().String() || traceTab["spanId"] != sc.SpanID().String() ||
+				traceTab["sampled"] != true || traceTab["link"] != expectedLink {
+				t.Errorf("%#+v\n", traceTab)
+			}
+			if event.MetaData["log"]["msg"] != "unable to load user" {
+				t.Errorf("Expected the message to be kept in the log tab; Got: %#+v\n", event.MetaData["log"])
+			}
+		case "GET /explicit", "cleanup-job":
+			// The span's name doesn't override a context that was chosen
+			if event.MetaData["trace"]["spanId"] != sc.SpanID().String() {
+				t.Errorf("%#+v\n", event.MetaData["trace"])
+			}
+		case "no span":
+			if _, ok := event.MetaData["trace"]; ok {
+				t.Errorf("Expected no trace tab; Got: %#+v\n", event.MetaData["trace"])
+			}
+		default:
+			t.Error("Unexpected event:", event.Context)
+		}
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || len(spans[0].Events) != 3 {
+		t.Fatalf("Expected 1 span with 3 events; Got: %#+v\n", spans)
+	}
+	event := spans[0].Events[0]
+	attrs := attribute.NewSet(event.Attributes...)
+	if msg, _ := attrs.Value("exception.message"); event.Name != "exception" || msg.AsString() != "terrible error" {
+		t.Errorf("%#+v\n", event)
+	}
+	if msg, _ := attrs.Value("log.message"); msg.AsString() != "unable to load user" {
+		t.Errorf("%#+v\n", event)
+	}
+}
+
+func TestCorrelatorDisabled(t *testing.T) {
+	t.Parallel()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+
+	notifier, events := newBugsnagTestServer(t)
+	notifiers := slogbugsnag.NewNotifierWorkers(&slogbugsnag.NotifierOptions{Notifier: notifier, MaxNotifierConcurrency: 1})
+	opts := &slogbugsnag.HandlerOptions{Notifiers: notifiers}
+	New(&Options{DisableContext: true}).Apply(opts)
+	logger := slog.New(slogbugsnag.NewHandler(slog.NewTextHandler(io.Discard, nil), opts))
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "span name")
+	logger.ErrorContext(ctx, "message")
+	span.End()
+
+	notifiers.Close()
+
+	got := events()
+	if len(got) != 1 || got[0].Context != "message" || got[0].MetaData["trace"]["link"] != nil {
+		t.Errorf("%#+v\n", got)
+	}
+	if spans := exporter.GetSpans(); len(spans) != 1 || len(spans[0].Events) != 0 {
+		t.Errorf("Expected no span events; Got: %#+v\n", spans)
+	}
+}
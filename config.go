@@ -0,0 +1,291 @@
+package slogbugsnag
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Config is the part of a Handler's options that can be changed while it is
+// running, with [Handler.Reconfigure].
+type Config struct {
+	// NotifyLevel is the minimum record level that will be sent to bugsnag.
+	// If nil, slog.LevelError is used. See HandlerOptions.NotifyLevel.
+	NotifyLevel slog.Leveler
+
+	// UnhandledLevel is the minimum record level that will be sent to bugsnag
+	// as an unhandled error. If nil, slog.LevelError + 4 is used.
+	UnhandledLevel slog.Leveler
+
+	// Redact configures the redaction of bugs. See HandlerOptions.Redact.
+	Redact *RedactOptions
+
+	// MaxNotifierConcurrency, if above zero, sets the maximum number of bugs
+	// that each of the handler's NotifierWorkers, including those it routes
+	// bugs to, can send in parallel, starting or stopping workers as needed.
+	// See [NotifierWorkers.SetConcurrency].
+	// If zero, the workers are left as they are. [Handler.Config] always
+	// returns zero, since each NotifierWorkers can have its own size.
+	MaxNotifierConcurrency int
+}
+
+// handlerConfig is the part of a Handler's configuration that can be
+// reconfigured. It is shared by the handler and all handlers derived from it.
+type handlerConfig struct {
+	notifyLevel    slog.Leveler
+	unhandledLevel slog.Leveler
+	redact         *RedactOptions
+	redaction      *redaction
+}
+
+// defaultHandlerConfig is used by handlers that were not created by NewHandler
+var defaultHandlerConfig = newHandlerConfig(Config{})
+
+// newHandlerConfig returns the handlerConfig for the config, with its defaults filled in
+func newHandlerConfig(cfg Config) *handlerConfig {
+	if cfg.NotifyLevel == nil {
+		cfg.NotifyLevel = slog.LevelError
+	}
+	if cfg.UnhandledLevel == nil {
+		cfg.UnhandledLevel = slog.LevelError + 4
+	}
+	return &handlerConfig{
+		notifyLevel:    cfg.NotifyLevel,
+		unhandledLevel: cfg.UnhandledLevel,
+		redact:         cfg.Redact,
+		redaction:      newRedaction(cfg.Redact),
+	}
+}
+
+// newLiveConfig returns an atomic pointer to the handlerConfig for the config
+func newLiveConfig(cfg Config) *atomic.Pointer[handlerConfig] {
+	live := &atomic.Pointer[handlerConfig]{}
+	live.Store(newHandlerConfig(cfg))
+	return live
+}
+
+// current returns the handler's configuration, as it was last reconfigured
+func (h *Handler) current() *handlerConfig {
+	if h.config == nil {
+		return defaultHandlerConfig
+	}
+	return h.config.Load()
+}
+
+// Config returns the handler's current configuration
+func (h *Handler) Config() Config {
+	c := h.current()
+	return Config{NotifyLevel: c.notifyLevel, UnhandledLevel: c.unhandledLevel, Redact: c.redact}
+}
+
+// Reconfigure atomically replaces the handler's configuration, for the handler
+// and all handlers derived from it with WithGroup or WithAttrs. It is safe to
+// call while logging from other goroutines. Bugs already queued keep the
+// configuration they were logged with, except for their redaction.
+// It returns an error if the handler was not created by NewHandler.
+func (h *Handler) Reconfigure(cfg Config) error {
+	if h.config == nil {
+		return errors.New("slogbugsnag: unable to reconfigure a Handler that was not created by NewHandler")
+	}
+	h.config.Store(newHandlerConfig(cfg))
+	if cfg.MaxNotifierConcurrency > 0 {
+		for _, nw := range h.allNotifiers() {
+			nw.SetConcurrency(cfg.MaxNotifierConcurrency)
+		}
+	}
+	return nil
+}
+
+// configFromEnv returns the base config, overridden by the reconfigurable
+// variables returned by get that are set, along with an error for each invalid variable.
+func configFromEnv(get func(key string) string, base Config) (Config, []error) {
+	var errs []error
+	cfg := base
+	if v := get(EnvNotifyLevel); v != "" {
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(v)); err != nil {
+			errs = append(errs, invalidEnv(EnvNotifyLevel, v, "must be a level such as ERROR or WARN+2"))
+		}
+		cfg.NotifyLevel = lvl
+	}
+	if v := get(EnvUnhandledLevel); v != "" {
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(v)); err != nil {
+			errs = append(errs, invalidEnv(EnvUnhandledLevel, v, "must be a level such as ERROR+4"))
+		}
+		cfg.UnhandledLevel = lvl
+	}
+	if v := get(EnvConcurrency); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			errs = append(errs, invalidEnv(EnvConcurrency, v, "must be a positive integer"))
+		}
+		cfg.MaxNotifierConcurrency = n
+	}
+	if keys := splitList(get(EnvRedactKeys)); len(keys) > 0 {
+		redact := RedactOptions{}
+		if base.Redact != nil {
+			redact = *base.Redact
+		}
+		redact.Keys = append(redact.Keys[:len(redact.Keys):len(redact.Keys)], keys...)
+		cfg.Redact = &redact
+	}
+	return cfg, errs
+}
+
+// watchedEnv are the variables that WatchConfig reconfigures the handler with
+var watchedEnv = []string{EnvNotifyLevel, EnvUnhandledLevel, EnvConcurrency, EnvRedactKeys}
+
+// WatchOptions are options for WatchConfig
+type WatchOptions struct {
+	// File is the path to a file of KEY=VALUE lines, such as an env file,
+	// using the names of the environment variables. Blank lines and lines
+	// starting with # are ignored. A missing file has no variables.
+	// If empty, the environment variables themselves are watched.
+	File string
+
+	// Interval is how often the file or environment is checked.
+	// Defaults to 10 seconds.
+	Interval time.Duration
+
+	// OnError is called when the file can't be read, or has invalid values,
+	// or the handler can't be reconfigured, in which case the handler is not
+	// reconfigured.
+	// If nil, the errors are logged to slog.Default() as warnings.
+	OnError func(err error)
+}
+
+// WatchConfig reconfigures the handler whenever the variables in the watched
+// file (or the environment) change, so that operators can turn on extra
+// reporting during an incident, without restarting. The variables are:
+// SLOG_BUGSNAG_NOTIFY_LEVEL, SLOG_BUGSNAG_UNHANDLED_LEVEL,
+// SLOG_BUGSNAG_CONCURRENCY, and SLOG_BUGSNAG_REDACT_KEYS.
+// See [HandlerOptionsFromEnv] for their format.
+// Variables that are not set fall back to the handler's configuration at the
+// time WatchConfig was called, so removing a variable reverts its change.
+// Removing SLOG_BUGSNAG_CONCURRENCY puts each of the handler's NotifierWorkers
+// back to the size it had before the variable was set.
+// It checks once before returning, then keeps checking in the background,
+// every Interval, until the context is done.
+// If opts is nil, the default options are used.
+func WatchConfig(ctx context.Context, h *Handler, opts *WatchOptions) {
+	if opts == nil {
+		opts = &WatchOptions{}
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	onError := opts.OnError
+	if onError == nil {
+		onError = func(err error) {
+			slog.Default().Warn("slog-bugsnag unable to reconfigure", slog.Any("err", err))
+		}
+	}
+
+	base := h.Config()
+	last := map[string]string{}
+	sizes := map[*NotifierWorkers]int{} // The size of each pool before it was first resized
+	applied := 0                        // The concurrency the pools were last resized to
+	check := func() {
+		vars, err := readWatchedEnv(opts.File)
+		if err != nil {
+			onError(err)
+			return
+		}
+		changed := false
+		for _, key := range watchedEnv {
+			if vars[key] != last[key] {
+				changed = true
+			}
+		}
+		if !changed {
+			return
+		}
+		last = vars
+
+		cfg, errs := configFromEnv(func(key string) string { return vars[key] }, base)
+		if len(errs) > 0 {
+			onError(errors.Join(errs...))
+			return
+		}
+		size := cfg.MaxNotifierConcurrency
+		cfg.MaxNotifierConcurrency = 0
+		if err := h.Reconfigure(cfg); err != nil {
+			onError(err)
+			return
+		}
+		if size == 0 && applied == 0 {
+			return
+		}
+		for _, nw := range h.allNotifiers() {
+			own, ok := sizes[nw]
+			if !ok {
+				own = nw.Stats().MaxWorkers
+				sizes[nw] = own
+			}
+			if size > 0 {
+				nw.SetConcurrency(size)
+			} else {
+				nw.SetConcurrency(own)
+			}
+		}
+		applied = size
+	}
+
+	check()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				check()
+			}
+		}
+	}()
+}
+
+// readWatchedEnv returns the watched variables, from the file if there is one,
+// otherwise from the environment
+func readWatchedEnv(file string) (map[string]string, error) {
+	vars := map[string]string{}
+	if file == "" {
+		for _, key := range watchedEnv {
+			vars[key] = strings.TrimSpace(os.Getenv(key))
+		}
+		return vars, nil
+	}
+
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return vars, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, _ := strings.Cut(line, "=")
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		vars[strings.TrimSpace(key)] = value
+	}
+	return vars, scanner.Err()
+}
@@ -0,0 +1,217 @@
+package slogbugsnag
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReconfigure(t *testing.T) {
+	t.Parallel()
+
+	svr := newBugsnagTestServer(t)
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 1})
+	routed := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 2})
+	defer routed.Close()
+	h := NewHandler(&testHandler{}, &HandlerOptions{
+		Notifiers:  notifiers,
+		RouteRules: []RouteRule{{Group: "routed", Notifiers: routed}},
+	})
+	logger := slog.New(h).WithGroup("g") // Derived handlers share the config
+
+	logger.Warn("before", "ssn", "123-45-6789")
+
+	// Changing only the level leaves each pool at its own size
+	cfg := h.Config()
+	cfg.UnhandledLevel = slog.LevelError + 8
+	if err := h.Reconfigure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if notifiers.Stats().MaxWorkers != 1 || routed.Stats().MaxWorkers != 2 {
+		t.Errorf("Expected the pools to keep their sizes; Got: %+v %+v", notifiers.Stats(), routed.Stats())
+	}
+
+	err := h.Reconfigure(Config{
+		NotifyLevel:            slog.LevelWarn,
+		UnhandledLevel:         slog.LevelWarn,
+		Redact:                 &RedactOptions{Keys: []string{"ssn"}},
+		MaxNotifierConcurrency: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = h.Config()
+	if cfg.NotifyLevel != slog.LevelWarn || cfg.UnhandledLevel != slog.LevelWarn || cfg.Redact == nil || cfg.MaxNotifierConcurrency != 0 {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+	if stats := notifiers.Stats(); stats.MaxWorkers != 3 {
+		t.Error("Expected 3 workers; Got:", stats.MaxWorkers)
+	}
+	if stats := routed.Stats(); stats.MaxWorkers != 3 {
+		t.Error("Expected the routed workers to be reconfigured too; Got:", stats.MaxWorkers)
+	}
+
+	logger.Warn("after", "ssn", "123-45-6789")
+	notifiers.Flush()
+
+	// Back to the defaults, with fewer workers
+	if err = h.Reconfigure(Config{MaxNotifierConcurrency: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if stats := notifiers.Stats(); stats.Workers != 1 {
+		t.Error("Expected 1 worker; Got:", stats.Workers)
+	}
+	logger.Warn("reverted")
+	logger.Error("error", "ssn", "123-45-6789")
+	notifiers.Close()
+
+	// Once closed, the workers can't be changed
+	notifiers.SetConcurrency(5)
+	if stats := notifiers.Stats(); stats.Workers != 0 || stats.MaxWorkers != 1 {
+		t.Errorf("Expected no workers, and a max of 1; Got: %+v", stats)
+	}
+
+	events := svr.Events()
+	if len(events) != 2 {
+		t.Fatal("Expected 2 bugsnag events; Got:", len(events))
+	}
+	if events[0].Context != "after" || !events[0].Unhandled || events[0].MetaData["g"]["ssn"] != "[FILTERED]" {
+		t.Errorf("%#+v\n", events[0])
+	}
+	if events[1].Context != "error" || events[1].Unhandled || events[1].MetaData["g"]["ssn"] != "123-45-6789" {
+		t.Errorf("%#+v\n", events[1])
+	}
+
+	// A handler that was not created by NewHandler has no config to replace
+	if err = (&Handler{}).Reconfigure(Config{}); err == nil {
+		t.Error("Expected an error reconfiguring a zero value Handler")
+	}
+}
+
+func TestReconfigureQueuedBugs(t *testing.T) {
+	t.Parallel()
+
+	gate := make(chan struct{})
+	svr := newGatedBugsnagTestServer(t, gate)
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 1})
+	h := NewHandler(&testHandler{}, &HandlerOptions{Notifiers: notifiers, NotifyLevel: slog.LevelWarn, UnhandledLevel: slog.LevelWarn})
+	logger := slog.New(h)
+
+	// The only worker is stuck sending the first bug
+	logger.Warn("first")
+	waitForStats(t, "first", notifiers, func(stats NotifierStats) bool { return stats.Queued == 0 && stats.PriorityQueued == 0 })
+
+	// Queued bugs keep the UnhandledLevel they were logged with
+	logger.Warn("unhandled")
+	if err := h.Reconfigure(Config{NotifyLevel: slog.LevelWarn}); err != nil {
+		t.Fatal(err)
+	}
+	logger.Warn("handled")
+
+	// The lanes agree with the handled state
+	if stats := notifiers.Stats(); stats.PriorityQueued != 1 || stats.Queued != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	close(gate)
+	notifiers.Close()
+
+	events := svr.Events()
+	if len(events) != 3 {
+		t.Fatal("Expected 3 bugsnag events; Got:", len(events))
+	}
+	for _, event := range events {
+		if event.Unhandled != (event.Context != "handled") {
+			t.Errorf("%#+v\n", event)
+		}
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	t.Parallel()
+
+	svr := newBugsnagTestServer(t)
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 2})
+	routed := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 3})
+	h := NewHandler(&testHandler{}, &HandlerOptions{
+		Notifiers:  notifiers,
+		RouteRules: []RouteRule{{Group: "routed", Notifiers: routed}},
+		Redact:     &RedactOptions{Keys: []string{"ssn"}},
+	})
+	defer h.Close()
+	sizes := func() (int, int) { return notifiers.Stats().MaxWorkers, routed.Stats().MaxWorkers }
+
+	var mu sync.Mutex
+	var errs []error
+	file := filepath.Join(t.TempDir(), "bugsnag.env")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	WatchConfig(ctx, h, &WatchOptions{File: file, Interval: 5 * time.Millisecond, OnError: func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}})
+
+	// waitFor waits until the handler's config passes the check
+	waitFor := func(name string, check func(cfg Config) bool) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if check(h.Config()) {
+				return
+			}
+		}
+		t.Fatalf("%s: Unexpected config: %+v", name, h.Config())
+	}
+
+	// No file, no changes
+	if cfg := h.Config(); cfg.NotifyLevel != slog.LevelError {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+	if size, routedSize := sizes(); size != 2 || routedSize != 3 {
+		t.Errorf("Unexpected sizes: %d %d", size, routedSize)
+	}
+
+	writeFile := func(content string) {
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("# Incident 123\nSLOG_BUGSNAG_NOTIFY_LEVEL=WARN\n\nSLOG_BUGSNAG_CONCURRENCY = 4\nSLOG_BUGSNAG_REDACT_KEYS=\"token\"\nOTHER=value\n")
+	waitFor("file", func(cfg Config) bool {
+		size, routedSize := sizes()
+		return cfg.NotifyLevel == slog.LevelWarn && size == 4 && routedSize == 4 &&
+			cfg.Redact != nil && len(cfg.Redact.Keys) == 2 && cfg.Redact.Keys[1] == "token"
+	})
+
+	writeFile("SLOG_BUGSNAG_NOTIFY_LEVEL=LOUD\n")
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		mu.Lock()
+		n := len(errs)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+	}
+	mu.Lock()
+	if len(errs) != 1 || errs[0].Error() != `slogbugsnag: invalid SLOG_BUGSNAG_NOTIFY_LEVEL "LOUD": must be a level such as ERROR or WARN+2` {
+		t.Error("Unexpected errors:", errs)
+	}
+	mu.Unlock()
+	if cfg := h.Config(); cfg.NotifyLevel != slog.LevelWarn {
+		t.Errorf("Expected the config to be unchanged; Got: %+v", cfg)
+	}
+
+	// Removing the file reverts to the config the watch started with,
+	// and puts each pool back to its own size
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("removed", func(cfg Config) bool {
+		size, routedSize := sizes()
+		return cfg.NotifyLevel == slog.LevelError && size == 2 && routedSize == 3 && len(cfg.Redact.Keys) == 1
+	})
+}
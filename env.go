@@ -0,0 +1,157 @@
+package slogbugsnag
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+)
+
+// Environment variables read by NewFromEnv and HandlerOptionsFromEnv.
+// The bugsnag ones are the same as those read by bugsnag.Configure.
+const (
+	EnvAPIKey              = "BUGSNAG_API_KEY"
+	EnvNotifyEndpoint      = "BUGSNAG_NOTIFY_ENDPOINT"
+	EnvSessionsEndpoint    = "BUGSNAG_SESSIONS_ENDPOINT"
+	EnvReleaseStage        = "BUGSNAG_RELEASE_STAGE"
+	EnvNotifyReleaseStages = "BUGSNAG_NOTIFY_RELEASE_STAGES"
+	EnvAppVersion          = "BUGSNAG_APP_VERSION"
+	EnvAppType             = "BUGSNAG_APP_TYPE"
+	EnvNotifyLevel         = "SLOG_BUGSNAG_NOTIFY_LEVEL"
+	EnvUnhandledLevel      = "SLOG_BUGSNAG_UNHANDLED_LEVEL"
+	EnvConcurrency         = "SLOG_BUGSNAG_CONCURRENCY"
+	EnvQueueSize           = "SLOG_BUGSNAG_QUEUE_SIZE"
+	EnvRedactKeys          = "SLOG_BUGSNAG_REDACT_KEYS"
+)
+
+// NewFromEnv creates a Handler, and the NotifierWorkers it sends bugs through,
+// configured from environment variables. See [HandlerOptionsFromEnv] for the
+// variables that are read.
+// It returns an error describing every invalid variable, if any are invalid.
+func NewFromEnv(next slog.Handler) (*Handler, error) {
+	opts, err := HandlerOptionsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewHandler(next, opts), nil
+}
+
+// HandlerOptionsFromEnv returns HandlerOptions configured from environment
+// variables, including started NotifierWorkers, so that more options can be
+// set before calling NewHandler. The variables are:
+//
+//   - BUGSNAG_API_KEY: the 32 hexadecimal character api key. Required.
+//   - BUGSNAG_NOTIFY_ENDPOINT: the url bugs are sent to.
+//   - BUGSNAG_SESSIONS_ENDPOINT: the url sessions are sent to. It requires
+//     BUGSNAG_NOTIFY_ENDPOINT to also be set.
+//   - BUGSNAG_RELEASE_STAGE: the release stage, such as "production".
+//   - BUGSNAG_NOTIFY_RELEASE_STAGES: comma separated release stages to send bugs in.
+//   - BUGSNAG_APP_VERSION: the version of the application.
+//   - BUGSNAG_APP_TYPE: the type of the application, such as "worker".
+//   - SLOG_BUGSNAG_NOTIFY_LEVEL: the NotifyLevel, such as "ERROR" or "WARN+2".
+//   - SLOG_BUGSNAG_UNHANDLED_LEVEL: the UnhandledLevel, such as "ERROR+4".
+//   - SLOG_BUGSNAG_CONCURRENCY: the MaxNotifierConcurrency, a positive integer.
+//   - SLOG_BUGSNAG_QUEUE_SIZE: the QueueSize, a positive integer.
+//   - SLOG_BUGSNAG_REDACT_KEYS: comma separated keys to redact, in addition
+//     to the notifier's ParamsFilters.
+//
+// Other than the api key, unset or empty variables keep their defaults, or
+// the values in the global bugsnag.Config.
+// It returns an error describing every invalid variable, if any are invalid,
+// in which case no NotifierWorkers are started.
+func HandlerOptionsFromEnv() (*HandlerOptions, error) {
+	return handlerOptionsFromEnv(os.Getenv)
+}
+
+// handlerOptionsFromEnv returns HandlerOptions configured from the variables returned by getenv
+func handlerOptionsFromEnv(getenv func(key string) string) (*HandlerOptions, error) {
+	var errs []error
+	invalid := func(key, value, reason string) {
+		errs = append(errs, invalidEnv(key, value, reason))
+	}
+	get := func(key string) string {
+		return strings.TrimSpace(getenv(key))
+	}
+
+	config := bugsnag.Configuration{
+		APIKey:       get(EnvAPIKey),
+		ReleaseStage: get(EnvReleaseStage),
+		AppVersion:   get(EnvAppVersion),
+		AppType:      get(EnvAppType),
+		Endpoints: bugsnag.Endpoints{
+			Notify:   get(EnvNotifyEndpoint),
+			Sessions: get(EnvSessionsEndpoint),
+		},
+		NotifyReleaseStages: splitList(get(EnvNotifyReleaseStages)),
+	}
+	if config.APIKey == "" {
+		errs = append(errs, fmt.Errorf("slogbugsnag: %s is required", EnvAPIKey))
+	} else if !isAPIKey(config.APIKey) {
+		invalid(EnvAPIKey, config.APIKey, "must be 32 hexadecimal characters")
+	}
+	for _, key := range []string{EnvNotifyEndpoint, EnvSessionsEndpoint} {
+		if endpoint := get(key); endpoint != "" {
+			if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				invalid(key, endpoint, "must be an absolute http or https url")
+			}
+		}
+	}
+	if config.Endpoints.Sessions != "" && config.Endpoints.Notify == "" {
+		// bugsnag.New panics, since it can't tell where to send the bugs
+		errs = append(errs, fmt.Errorf("slogbugsnag: %s requires %s to also be set", EnvSessionsEndpoint, EnvNotifyEndpoint))
+	}
+
+	cfg, cfgErrs := configFromEnv(get, Config{})
+	errs = append(errs, cfgErrs...)
+	opts := &HandlerOptions{NotifyLevel: cfg.NotifyLevel, UnhandledLevel: cfg.UnhandledLevel, Redact: cfg.Redact}
+	notifierOpts := &NotifierOptions{MaxNotifierConcurrency: cfg.MaxNotifierConcurrency}
+	if v := get(EnvQueueSize); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			invalid(EnvQueueSize, v, "must be a positive integer")
+		}
+		notifierOpts.QueueSize = n
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	notifierOpts.Notifier = bugsnag.New(config)
+	opts.Notifiers = NewNotifierWorkers(notifierOpts)
+	return opts, nil
+}
+
+// invalidEnv returns an error describing the invalid environment variable
+func invalidEnv(key, value, reason string) error {
+	return fmt.Errorf("slogbugsnag: invalid %s %q: %s", key, value, reason)
+}
+
+// splitList splits a comma separated list, dropping empty items
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// isAPIKey returns true if the key looks like a bugsnag api key
+func isAPIKey(key string) bool {
+	if len(key) != 32 {
+		return false
+	}
+	for _, c := range key {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return true
+}
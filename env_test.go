@@ -0,0 +1,95 @@
+package slogbugsnag
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestHandlerOptionsFromEnv(t *testing.T) {
+	t.Parallel()
+
+	svr := newBugsnagTestServer(t)
+	env := map[string]string{
+		EnvAPIKey:              "1234567890abcdef1234567890ABCDEF",
+		EnvNotifyEndpoint:      svr.URL,
+		EnvSessionsEndpoint:    svr.URL,
+		EnvReleaseStage:        "staging",
+		EnvNotifyReleaseStages: "production, staging",
+		EnvAppVersion:          "1.2.3",
+		EnvNotifyLevel:         "WARN",
+		EnvUnhandledLevel:      "ERROR+2",
+		EnvConcurrency:         "2",
+		EnvQueueSize:           "10",
+		EnvRedactKeys:          "ssn,,token ",
+	}
+	opts, err := handlerOptionsFromEnv(func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opts.NotifyLevel != slog.LevelWarn || opts.UnhandledLevel != slog.LevelError+2 {
+		t.Errorf("Unexpected levels: %v %v", opts.NotifyLevel, opts.UnhandledLevel)
+	}
+	if stats := opts.Notifiers.Stats(); stats.Workers != 2 || cap(opts.Notifiers.bugsCh) != 10 {
+		t.Errorf("Unexpected workers: %+v %d", stats, cap(opts.Notifiers.bugsCh))
+	}
+	if opts.Redact == nil || strings.Join(opts.Redact.Keys, ",") != "ssn,token" {
+		t.Errorf("Unexpected redaction: %#+v", opts.Redact)
+	}
+	config := opts.Notifiers.notifier.Config
+	if config.APIKey != env[EnvAPIKey] || config.ReleaseStage != "staging" || config.AppVersion != "1.2.3" ||
+		strings.Join(config.NotifyReleaseStages, ",") != "production,staging" {
+		t.Errorf("Unexpected config: %#+v", config)
+	}
+
+	logger := slog.New(NewHandler(&testHandler{}, opts))
+	logger.Warn("warning", "token", "abc", "ok", "value")
+	logger.Info("info")
+	opts.Notifiers.Close()
+
+	events := svr.Events()
+	if len(events) != 1 {
+		t.Fatal("Expected 1 bugsnag event; Got:", len(events))
+	}
+	if events[0].Context != "warning" || events[0].MetaData["log"]["token"] != "[FILTERED]" || events[0].MetaData["log"]["ok"] != "value" {
+		t.Errorf("%#+v\n", events[0])
+	}
+}
+
+func TestHandlerOptionsFromEnvInvalid(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{
+		EnvNotifyEndpoint:   "notify.example.com",
+		EnvSessionsEndpoint: "https://sessions.example.com",
+		EnvNotifyLevel:      "LOUD",
+		EnvConcurrency:      "0",
+		EnvQueueSize:        "lots",
+	}
+	opts, err := handlerOptionsFromEnv(func(key string) string { return env[key] })
+	if opts != nil || err == nil {
+		t.Fatal("Expected an error; Got:", opts)
+	}
+
+	expected := `slogbugsnag: BUGSNAG_API_KEY is required
+slogbugsnag: invalid BUGSNAG_NOTIFY_ENDPOINT "notify.example.com": must be an absolute http or https url
+slogbugsnag: invalid SLOG_BUGSNAG_NOTIFY_LEVEL "LOUD": must be a level such as ERROR or WARN+2
+slogbugsnag: invalid SLOG_BUGSNAG_CONCURRENCY "0": must be a positive integer
+slogbugsnag: invalid SLOG_BUGSNAG_QUEUE_SIZE "lots": must be a positive integer`
+	if err.Error() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, err)
+	}
+
+	env = map[string]string{EnvAPIKey: "not-a-key"}
+	if _, err = handlerOptionsFromEnv(func(key string) string { return env[key] }); err == nil ||
+		err.Error() != `slogbugsnag: invalid BUGSNAG_API_KEY "not-a-key": must be 32 hexadecimal characters` {
+		t.Error("Unexpected error:", err)
+	}
+
+	env = map[string]string{EnvAPIKey: "1234567890abcdef1234567890abcdef", EnvSessionsEndpoint: "https://sessions.example.com"}
+	if _, err = handlerOptionsFromEnv(func(key string) string { return env[key] }); err == nil ||
+		err.Error() != `slogbugsnag: BUGSNAG_SESSIONS_ENDPOINT requires BUGSNAG_NOTIFY_ENDPOINT to also be set` {
+		t.Error("Unexpected error:", err)
+	}
+}
@@ -50,6 +50,19 @@ func (e errorWithCallers) String() string {
 	return fmt.Sprintf("%T: %v\n\t%s", e.error, e.error, strings.Join(debugStack(e.stack), "\n\t"))
 }
 
+// redactedError replaces the message of an error that contains secrets,
+// while still unwrapping to the original error
+type redactedError struct {
+	error
+	msg string
+}
+
+// Error returns the redacted message
+func (e redactedError) Error() string { return e.msg }
+
+// Unwrap provides compatibility for Go 1.13 error chains.
+func (e redactedError) Unwrap() error { return e.error }
+
 // debugStack returns the frame stack in string format for debugging
 func debugStack(pcs []uintptr) []string {
 	var trace []string
@@ -63,26 +76,38 @@ func debugStack(pcs []uintptr) []string {
 	return trace
 }
 
+// callers captures the program counters of the current goroutine's stack.
+// It is called on the logging goroutine, because the stack can't be recovered
+// once the log call has returned. It is skipped if the error already has a stack.
+func callers() []uintptr {
+	stack := make([]uintptr, bserrors.MaxStackDepth)
+	length := runtime.Callers(2, stack[:])
+	return stack[:length]
+}
+
+// hasStack returns true if the error already carries a full stack trace
+func hasStack(err error) bool {
+	switch err.(type) {
+	case *bserrors.Error, withCallers, withBSStackFrames, withPStackTrace:
+		return true
+	}
+	return false
+}
+
 // newErrorWithStack ensures we have a non-nil error that includes a full stack
-// trace, using either the one it came with or generating one from the log line
-func newErrorWithStack(errForBugsnag error, msg string, pc uintptr) error {
+// trace, using either the one it came with or the one captured at the log call
+func newErrorWithStack(errForBugsnag error, msg string, pc uintptr, stack []uintptr) error {
 	// Ensure the error is not nil. Use the log message for the error if not.
 	if errForBugsnag == nil {
 		errForBugsnag = errors.New(msg)
 	}
 
 	// Ensure our error has a caller/stack/frame trace
-	switch errForBugsnag.(type) {
-	case *bserrors.Error, withCallers, withBSStackFrames, withPStackTrace:
+	if hasStack(errForBugsnag) {
 		// Do nothing, these errors already have a full stack
 		return errForBugsnag
 	}
 
-	// Recreate the callers stack trace, based on the log program counter
-	stack := make([]uintptr, bserrors.MaxStackDepth)
-	length := runtime.Callers(0, stack[:])
-	stack = stack[:length]
-
 	// Iterate until we find our log line program counter, then return a
 	// wrapped error with the remaining stack callers
 	for idx, ptr := range stack {
@@ -94,7 +119,14 @@ func newErrorWithStack(errForBugsnag error, msg string, pc uintptr) error {
 		}
 	}
 
-	// This can only happen if a handler edited the PC. In that case, let
-	// bugsnag create a full stack trace, which will include the log handlers.
+	// This can only happen if a handler edited the PC. In that case, use the
+	// full captured stack trace, which will include the log handlers, since
+	// bugsnag would only find the stack of the worker building the bug.
+	if len(stack) > 0 {
+		return errorWithCallers{
+			error: errForBugsnag,
+			stack: stack,
+		}
+	}
 	return errForBugsnag
 }
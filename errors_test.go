@@ -1,9 +1,14 @@
 package slogbugsnag
 
 import (
+	"context"
+	"errors"
+	"log/slog"
 	"runtime"
+	"strings"
 	"testing"
 
+	"github.com/bugsnag/bugsnag-go/v2"
 	perrors "github.com/pkg/errors"
 )
 
@@ -11,7 +16,7 @@ func TestNewErrorWithStackNil(t *testing.T) {
 	t.Parallel()
 
 	pc, _, _, _ := runtime.Caller(1)
-	err := newErrorWithStack(nil, "oh no", pc+1)
+	err := newErrorWithStack(nil, "oh no", pc+1, callers())
 	if err == nil {
 		t.Fatal("expected non-nil error")
 	}
@@ -36,7 +41,7 @@ func TestNewErrorWithStackExisting(t *testing.T) {
 	t.Parallel()
 
 	origErr := perrors.New("an error")
-	err := newErrorWithStack(origErr, "oh no", 0)
+	err := newErrorWithStack(origErr, "oh no", 0, nil)
 	if err == nil {
 		t.Fatal("expected non-nil error")
 	}
@@ -45,3 +50,60 @@ func TestNewErrorWithStackExisting(t *testing.T) {
 		t.Fatal("expected github.com/pkg/errors error")
 	}
 }
+
+func TestErrorHasStack(t *testing.T) {
+	t.Parallel()
+
+	h := NewHandler(&testHandler{}, &HandlerOptions{Notifiers: &NotifierWorkers{}})
+	newRecord := func(args ...any) slog.Record {
+		r := slog.NewRecord(defaultTime, slog.LevelError, "msg", 0)
+		r.Add(args...)
+		return r
+	}
+
+	if h.errorHasStack(newRecord()) {
+		t.Error("Expected no error to have no stack")
+	}
+	if h.errorHasStack(newRecord("err", errors.New("plain"))) {
+		t.Error("Expected a plain error to have no stack")
+	}
+	if !h.errorHasStack(newRecord("err", perrors.New("with stack"))) {
+		t.Error("Expected a pkg/errors error to have a stack")
+	}
+	if h.errorHasStack(newRecord("err", perrors.New("with stack"), slog.Group("g", "err", errors.New("latest")))) {
+		t.Error("Expected the latest error to be checked")
+	}
+	if !h.WithAttrs([]slog.Attr{slog.Any("err", perrors.New("with stack"))}).(*Handler).errorHasStack(newRecord("a", 1)) {
+		t.Error("Expected the handler's error to be checked")
+	}
+}
+
+func TestStackWithoutPC(t *testing.T) {
+	t.Parallel()
+
+	var methods []string
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: newBugsnagTestServer(t).Notifier(), MaxNotifierConcurrency: 1})
+	h := NewHandler(&testHandler{}, &HandlerOptions{
+		Notifiers: notifiers,
+		BeforeNotify: []func(event *bugsnag.Event){
+			func(event *bugsnag.Event) {
+				for _, frame := range event.Stacktrace {
+					methods = append(methods, frame.Method)
+				}
+			},
+		},
+	})
+
+	// A record without a PC has no log line to start the stack at, so the
+	// whole stack captured at the log call is used, not the worker's
+	r := slog.NewRecord(defaultTime, slog.LevelError, "no pc", 0)
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	notifiers.Close()
+
+	trace := strings.Join(methods, "\n")
+	if !strings.Contains(trace, "TestStackWithoutPC") || strings.Contains(trace, "NotifierWorkers") {
+		t.Error("Expected the stack of the log call; Got:\n" + trace)
+	}
+}
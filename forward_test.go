@@ -0,0 +1,49 @@
+package slogbugsnag
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestHandlerForwardGroupsAndAttrs(t *testing.T) {
+	t.Parallel()
+
+	svr := newBugsnagTestServer(t)
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier()})
+
+	buf := &bytes.Buffer{}
+	next := slog.NewTextHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+
+	h := NewHandler(next, &HandlerOptions{Notifiers: notifiers, ForwardGroupsAndAttrs: true})
+
+	log := slog.New(h)
+	log = log.With("with1", "arg0")
+	log = log.WithGroup("group1")
+	log.Error("main message", "main1", "arg0")
+
+	// The next handler did the grouping itself, using its own WithGroup/WithAttrs
+	expectedLog := `level=ERROR msg="main message" with1=arg0 group1.main1=arg0`
+	if strings.TrimSpace(buf.String()) != expectedLog {
+		t.Error("Received:", buf.String())
+	}
+
+	// Flush the channel and workers
+	h.Close()
+
+	events := svr.Events()
+	if len(events) != 1 {
+		t.Fatal("Expected 1 bugsnag event; Got:", len(events))
+	}
+	if events[0].MetaData["log"]["with1"] != "arg0" || events[0].MetaData["group1"]["main1"] != "arg0" {
+		t.Errorf("%#+v\n", events[0].MetaData)
+	}
+}
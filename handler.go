@@ -4,7 +4,6 @@ import (
 	"context"
 	"log/slog"
 	"runtime"
-	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -23,8 +22,25 @@ type NotifierOptions struct {
 	// to bugsnag in parallel. It defaults to the number of CPU's.
 	// Bugs are placed on a buffered channel to be sent to bugsnag, in order
 	// to not block or delay the log call from returning. The bugs are then
-	// sent to bugsnag synchronously by a number of workers equal to this int.
+	// sent to bugsnag synchronously by a number of workers equal to this int,
+	// or between Autoscale.MinWorkers and this int, if autoscaling.
 	MaxNotifierConcurrency int
+
+	// QueueSize is the capacity of the buffered channel that bugs are placed
+	// on, waiting to be sent. Bugs logged while it is full are dropped.
+	// It defaults to 4000.
+	QueueSize int
+
+	// PriorityQueueSize is the capacity of a separate queue for unhandled bugs,
+	// which the workers always take bugs from first. This way a burst of
+	// handled bugs can neither delay an unhandled bug, nor fill up the queue
+	// so that it gets dropped. It defaults to 100.
+	PriorityQueueSize int
+
+	// Autoscale, if set, starts workers when bugs are queuing up, up to
+	// MaxNotifierConcurrency, and stops them when they are idle.
+	// If nil, MaxNotifierConcurrency workers are always running.
+	Autoscale *AutoscaleOptions
 }
 
 // NotifierWorkers can run a worker pool, where each worker
@@ -33,8 +49,31 @@ type NotifierOptions struct {
 type NotifierWorkers struct {
 	notifier *bugsnag.Notifier
 	workerWG sync.WaitGroup
-	bugsCh   chan bugRecord
 	isClosed atomic.Bool
+
+	// priorityCh queues unhandled bugs, and bugsCh queues all others
+	priorityCh chan bugRecord
+	bugsCh     chan bugRecord
+
+	// mu guards the workers' stop channels, one per running worker,
+	// and the maximum number of workers
+	mu         sync.Mutex
+	stops      []chan struct{}
+	maxWorkers int
+
+	// autoscale is nil if the number of workers is fixed
+	autoscale *autoscaler
+
+	// sent and dropped count the bugs sent to bugsnag, and those dropped
+	// because their queue was full
+	sent            atomic.Uint64
+	dropped         atomic.Uint64
+	priorityDropped atomic.Uint64
+
+	// pending counts the bugs that are queued or being sent, for Flush
+	pendingMu   sync.Mutex
+	pendingIdle *sync.Cond
+	pending     int
 }
 
 // NewNotifierWorkers creates and starts a worker pool, where each worker
@@ -47,47 +86,282 @@ func NewNotifierWorkers(opts *NotifierOptions) *NotifierWorkers {
 	if opts.MaxNotifierConcurrency < 1 {
 		opts.MaxNotifierConcurrency = runtime.NumCPU()
 	}
+	if opts.QueueSize < 1 {
+		opts.QueueSize = 4000
+	}
+	if opts.PriorityQueueSize < 1 {
+		opts.PriorityQueueSize = 100
+	}
 	if opts.Notifier == nil {
 		opts.Notifier = bugsnag.New()
 	}
 
 	workers := &NotifierWorkers{
-		notifier: opts.Notifier,
-		bugsCh:   make(chan bugRecord, 4000),
-		workerWG: sync.WaitGroup{},
-		isClosed: atomic.Bool{},
+		notifier:   opts.Notifier,
+		priorityCh: make(chan bugRecord, opts.PriorityQueueSize),
+		bugsCh:     make(chan bugRecord, opts.QueueSize),
+		workerWG:   sync.WaitGroup{},
+		isClosed:   atomic.Bool{},
+		maxWorkers: opts.MaxNotifierConcurrency,
+		autoscale:  newAutoscaler(opts.Autoscale, opts.MaxNotifierConcurrency),
 	}
 
-	workers.start(opts.MaxNotifierConcurrency)
+	if workers.autoscale != nil {
+		workers.start(workers.autoscale.minWorkers)
+		go workers.autoscaleLoop()
+	} else {
+		workers.start(opts.MaxNotifierConcurrency)
+	}
 	return workers
 }
 
-// start runs a number of goroutines that consume from the bugsCh
+// start runs a number of goroutines that consume from the queues
 // and notify bugsnag.
 func (nw *NotifierWorkers) start(workerCount int) {
-	nw.workerWG.Add(workerCount)
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	if nw.maxWorkers < workerCount {
+		nw.maxWorkers = workerCount
+	}
 	for i := 0; i < workerCount; i++ {
-		go func() {
-			defer nw.workerWG.Done()
-			for bug := range nw.bugsCh {
-				// Notify Bugsnag. Ignore the error because bugsnag has already logged it.
-				_ = nw.notifier.NotifySync(bug.err, true, bug.rawData...)
+		nw.startWorker()
+	}
+}
+
+// startWorker runs a goroutine that consumes from the queues and notifies
+// bugsnag, until the queues are closed and empty, or the worker is stopped.
+// Bugs in the priorityCh are always taken before those in the bugsCh.
+// If autoscaling, the worker also stops itself once it has been idle for long
+// enough, unless the pool is at its minimum.
+// Must be called with the mu held.
+func (nw *NotifierWorkers) startWorker() {
+	stop := make(chan struct{})
+	nw.stops = append(nw.stops, stop)
+	nw.workerWG.Add(1)
+	go func() {
+		defer nw.workerWG.Done()
+
+		var idle *time.Timer
+		var idleC <-chan time.Time
+		if nw.autoscale != nil {
+			idle = time.NewTimer(nw.autoscale.idleTime)
+			defer idle.Stop()
+			idleC = idle.C
+		}
+
+		// Each queue is set to nil once it is closed and empty
+		priorityCh, bugsCh := nw.priorityCh, nw.bugsCh
+		for priorityCh != nil || bugsCh != nil {
+			var bug bugRecord
+			var ok bool
+			select {
+			case bug, ok = <-priorityCh:
+			default:
+				select {
+				case <-stop:
+					return
+				case <-idleC:
+					if nw.retire(stop) {
+						return
+					}
+					idle.Reset(nw.autoscale.idleTime)
+					continue
+				case bug, ok = <-priorityCh:
+				case bug, ok = <-bugsCh:
+					if !ok {
+						bugsCh = nil
+						continue
+					}
+				}
 			}
-		}()
+			if !ok {
+				priorityCh = nil
+				continue
+			}
+
+			nw.send(bug)
+			if idle != nil {
+				resetTimer(idle, nw.autoscale.idleTime)
+			}
+		}
+	}()
+}
+
+// send builds the bug and sends it to bugsnag
+func (nw *NotifierWorkers) send(bug bugRecord) {
+	if nw.autoscale != nil {
+		nw.autoscale.dequeued(bug.queued)
+	}
+
+	// Build the bug from the captured record, off the logging goroutine
+	bug.notifier = nw.notifier
+	report := bug.h.logToBug(bug)
+
+	// Notify Bugsnag. Ignore the error because bugsnag has already logged it.
+	_ = nw.notifier.NotifySync(report.err, true, report.rawData...)
+	nw.sent.Add(1)
+	nw.donePending()
+	if bug.waiter != nil {
+		bug.waiter.wg.Done()
+	}
+}
+
+// SetConcurrency sets the maximum number of bugs that can be sent to bugsnag
+// in parallel, starting or stopping workers as needed. If autoscaling, workers
+// are only stopped down to the new maximum, and only started up to the minimum.
+// Workers that are stopped finish sending their current bug first.
+// It does nothing once closed. The concurrency can not go below 1.
+func (nw *NotifierWorkers) SetConcurrency(workerCount int) {
+	if workerCount < 1 {
+		workerCount = 1
+	}
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	if nw.closed() {
+		return
+	}
+	nw.maxWorkers = workerCount
+
+	target := workerCount
+	if nw.autoscale != nil {
+		target = min(max(len(nw.stops), nw.autoscale.minWorkers), workerCount)
+	}
+	for len(nw.stops) < target {
+		nw.startWorker()
+	}
+	for len(nw.stops) > target {
+		last := len(nw.stops) - 1
+		close(nw.stops[last])
+		nw.stops = nw.stops[:last]
 	}
 }
 
+// NotifierStats are statistics about NotifierWorkers
+type NotifierStats struct {
+	// Workers is the number of workers sending bugs to bugsnag
+	Workers int
+
+	// MinWorkers and MaxWorkers are the bounds of the number of workers.
+	// They are both equal to Workers, unless autoscaling.
+	MinWorkers int
+	MaxWorkers int
+
+	// Queued is the number of bugs waiting in the queues, and PriorityQueued
+	// is how many of them are unhandled bugs, in the priority queue
+	Queued         int
+	PriorityQueued int
+
+	// Wait is how long the latest bug waited in the queue. Only measured when autoscaling.
+	Wait time.Duration
+
+	// ScaleUps and ScaleDowns are the number of workers started and stopped
+	// so far by autoscaling
+	ScaleUps   uint64
+	ScaleDowns uint64
+
+	// Sent is the number of bugs sent to bugsnag so far
+	Sent uint64
+
+	// Dropped is the number of bugs dropped so far, because their queue was
+	// full, and PriorityDropped is how many of them were unhandled bugs
+	Dropped         uint64
+	PriorityDropped uint64
+}
+
+// Stats returns statistics about the NotifierWorkers
+func (nw *NotifierWorkers) Stats() NotifierStats {
+	nw.mu.Lock()
+	workers, maxWorkers := len(nw.stops), nw.maxWorkers
+	nw.mu.Unlock()
+
+	stats := NotifierStats{
+		Workers:         workers,
+		MinWorkers:      maxWorkers,
+		MaxWorkers:      maxWorkers,
+		Queued:          nw.queued(),
+		PriorityQueued:  len(nw.priorityCh),
+		Sent:            nw.sent.Load(),
+		Dropped:         nw.dropped.Load(),
+		PriorityDropped: nw.priorityDropped.Load(),
+	}
+	if nw.autoscale != nil {
+		stats.MinWorkers = min(nw.autoscale.minWorkers, maxWorkers)
+		stats.Wait = time.Duration(nw.autoscale.wait.Load())
+		stats.ScaleUps = nw.autoscale.scaleUps.Load()
+		stats.ScaleDowns = nw.autoscale.scaleDowns.Load()
+	}
+	return stats
+}
+
+// queued returns the number of bugs waiting in the queues
+func (nw *NotifierWorkers) queued() int {
+	return len(nw.priorityCh) + len(nw.bugsCh)
+}
+
 // closed returns if the NotifierWorkers are closed and not accepting new bugs
 func (nw *NotifierWorkers) closed() bool {
 	return nw.isClosed.Load()
 }
 
+// addPending counts a bug that is about to be queued
+func (nw *NotifierWorkers) addPending() {
+	nw.pendingMu.Lock()
+	nw.pending++
+	nw.pendingMu.Unlock()
+}
+
+// donePending counts a bug that has been sent, or could not be queued
+func (nw *NotifierWorkers) donePending() {
+	nw.pendingMu.Lock()
+	defer nw.pendingMu.Unlock()
+	nw.pending--
+	if nw.pending <= 0 && nw.pendingIdle != nil {
+		nw.pendingIdle.Broadcast()
+	}
+}
+
+// Flush blocks until all bugs currently queued have been sent, without
+// closing the NotifierWorkers. Bugs logged while waiting are also waited for.
+func (nw *NotifierWorkers) Flush() {
+	nw.pendingMu.Lock()
+	defer nw.pendingMu.Unlock()
+	if nw.pendingIdle == nil {
+		nw.pendingIdle = sync.NewCond(&nw.pendingMu)
+	}
+	for nw.pending > 0 {
+		nw.pendingIdle.Wait()
+	}
+}
+
 // Close stops the NotifierWorkers from accepting any new bugs to its queue.
 // This call will block until all bugs currently queued have been sent.
+// If autoscaling, workers are started up to the maximum, to drain the queue
+// faster, and none are stopped for being idle until it is drained.
+// It is safe to call more than once, such as when the NotifierWorkers are
+// shared by several handlers; later calls only wait for the queue to drain.
 func (nw *NotifierWorkers) Close() {
+	nw.mu.Lock()
+	if nw.closed() {
+		nw.mu.Unlock()
+		nw.workerWG.Wait()
+		return
+	}
+	if nw.autoscale != nil {
+		for queued := nw.queued(); queued > 0 && len(nw.stops) < nw.maxWorkers; queued-- {
+			nw.startWorker()
+			nw.autoscale.scaleUps.Add(1)
+		}
+		close(nw.autoscale.done)
+	}
 	nw.isClosed.Store(true)
+	close(nw.priorityCh)
 	close(nw.bugsCh)
+	nw.mu.Unlock()
 	nw.workerWG.Wait()
+
+	nw.mu.Lock()
+	nw.stops = nil
+	nw.mu.Unlock()
 }
 
 // HandlerOptions are options for a Handler
@@ -98,7 +372,7 @@ type HandlerOptions struct {
 	// to the next handler.
 	// If NotifyLevel is nil, the handler assumes LevelError.
 	// The handler calls NotifyLevel.Level() for each record processed;
-	// to adjust the minimum level dynamically, use a LevelVar.
+	// to adjust the minimum level dynamically, use a LevelVar, or [Handler.Reconfigure].
 	NotifyLevel slog.Leveler
 
 	// UnhandledLevel reports the minimum record level that will be sent to
@@ -111,8 +385,118 @@ type HandlerOptions struct {
 	// terminating an application, by calling Close on the pool or the handler.
 	// If nil, a default notifier worker pool will be started.
 	Notifiers *NotifierWorkers
+
+	// Route picks the NotifierWorkers that each bug is sent through, such as
+	// one for the bugsnag project of the team that owns the code that logged it.
+	// Each NotifierWorkers has its own notifier, worker pool, stats, and shutdown,
+	// rather than only its own notifier, so that a slow or unreachable project
+	// can't fill the queue and hold up the bugs bound for the others.
+	// If Route is nil or returns nil, RouteRules are checked next.
+	// Flushing or closing the handler also flushes or closes every
+	// NotifierWorkers that Route has returned so far.
+	// Route is called on the logging goroutine, and should be fast.
+	Route func(ctx context.Context, r slog.Record) *NotifierWorkers
+
+	// RouteRules route the bugs they match through their own NotifierWorkers.
+	// The first matching rule wins. Bugs that no rule matches are sent
+	// through Notifiers. Flushing or closing the handler also flushes or
+	// closes the rules' NotifierWorkers.
+	RouteRules []RouteRule
+
+	// ForwardGroupsAndAttrs, if true, forwards WithGroup and WithAttrs calls to
+	// the next handler, and passes records along to it untouched, the way
+	// typical slog middleware does. This preserves the next handler's own
+	// state and any pre-formatting it does. The handler still keeps its own
+	// copy of the groups and attributes, for building the bugsnag metadata.
+	// If false (the default), groups and attributes are folded into each
+	// record before it is passed to the next handler.
+	ForwardGroupsAndAttrs bool
+
+	// TabNamer decides which bugsnag metadata tab the attributes inside each
+	// group are put in. Root-level attributes always go in the "log" tab.
+	// If nil, InnermostGroupTab is used, which names the tab after the innermost group.
+	// See also TopLevelGroupTab and GroupPathTab.
+	TabNamer TabNamer
+
+	// ReplaceAttr is called to rewrite each non-group attribute before it is
+	// put in the bugsnag metadata. It has the same contract as
+	// [slog.HandlerOptions.ReplaceAttr], but only affects what is sent to
+	// bugsnag, not the records passed to the next handler.
+	// The attribute's value has been resolved. If ReplaceAttr returns a zero
+	// Attr (an empty key and a nil value), the attribute is dropped. Like slog,
+	// other attributes with an empty key are kept, and groups with an empty key are inlined.
+	// The built-in attributes with keys "time", "level", "source", and "msg"
+	// of the log tab are also passed to this function, with nil groups.
+	// The error and user are found before ReplaceAttr is called, so dropping
+	// them only removes them from the metadata.
+	// ReplaceAttr is called from the NotifierWorkers' goroutines.
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+
+	// Redact configures the redaction of secrets and personally identifiable
+	// information out of bugs before they are sent to bugsnag.
+	// Handlers sharing the same NotifierWorkers can each have their own policy.
+	// If nil, only metadata keys matching the notifier config ParamsFilters are redacted.
+	Redact *RedactOptions
+
+	// Limits are limits on the size of the events sent to bugsnag, which
+	// rejects events that are too large. Values over the limits are truncated.
+	// If nil, there are no limits.
+	Limits *LimitOptions
+
+	// ValueEncoder converts values of custom types into values that bugsnag can
+	// display. All attribute values are encoded into something readable:
+	// durations and times as strings, [slog.LogValuer], [fmt.Stringer],
+	// [encoding.TextMarshaler], and [json.Marshaler] are honored, and maps,
+	// slices, and structs are converted to nested maps and slices.
+	// If nil, or if it returns false, the default encoding is used.
+	// ValueEncoder is called from the NotifierWorkers' goroutines.
+	ValueEncoder ValueEncoder
+
+	// Breadcrumbs configures keeping recent records below the NotifyLevel, and
+	// attaching them to each bug, to show what happened just before it.
+	// If nil, no breadcrumbs are kept.
+	Breadcrumbs *BreadcrumbOptions
+
+	// UserFromContext returns the user to fill in on the [bugsnag.User] of
+	// each bug, such as the principal stored in the context by an auth
+	// middleware. It overrides the user from [ContextWithUser], field by field,
+	// and user log attributes override both, field by field.
+	// UserFromContext is called from the NotifierWorkers' goroutines.
+	UserFromContext func(ctx context.Context) (bugsnag.User, bool)
+
+	// ContextExtractors return attributes stored in the context, such as those
+	// added by [github.com/veqryn/slog-context], to be put in the bugsnag
+	// metadata, regardless of the order of the handlers in the pipeline.
+	// The extracted attributes are at the root level, before the handler's and
+	// the record's attributes, and only affect what is sent to bugsnag.
+	// ContextExtractors are called from the NotifierWorkers' goroutines.
+	ContextExtractors []ContextExtractor
+
+	// OnNotify are called with each record that will be sent to bugsnag, and
+	// the latest error among its attributes (or nil), before it is queued.
+	// They are called on the logging goroutine, so that they can act on state
+	// that may not last, such as the span in the context, and should be fast.
+	OnNotify []func(ctx context.Context, r slog.Record, err error)
+
+	// BeforeNotify are called with each bugsnag event, after it has been built
+	// and before it is sent, to modify it. The log call's context is event.Ctx.
+	// Value redaction is applied after them.
+	// BeforeNotify are called from the NotifierWorkers' goroutines.
+	BeforeNotify []func(event *bugsnag.Event)
+
+	// BugsnagContext picks the bugsnag context of each bug, which is shown as
+	// its title in bugsnag, from the record (which does not include the
+	// handler's attributes). If it returns an empty string, the log message is used.
+	// A log attribute of type [Context], or a context string set with
+	// [ContextWithBugsnagContext], takes precedence over it.
+	// The log message is still sent, in the log tab.
+	// BugsnagContext is called from the NotifierWorkers' goroutines.
+	BugsnagContext func(ctx context.Context, r slog.Record) string
 }
 
+// ContextExtractor returns attributes stored in the context
+type ContextExtractor func(ctx context.Context) []slog.Attr
+
 // Handler is a slog.Handler middleware that will automatically send log
 // lines to Bugsnag (https://www.bugsnag.com/) if they are at least a certain
 // level (Error by default).
@@ -120,15 +504,30 @@ type HandlerOptions struct {
 // attributes and the context are put into metadata and user tabs and sent with
 // the bug.
 // It passes the final record and attributes off to the next handler when finished.
+// Bugs are built by the NotifierWorkers, off the logging goroutine, so attribute
+// values (including [slog.LogValuer]'s) must be safe to read from another goroutine.
 // The Bugsnag V2 library should be configured before any logging is done.
 //
 //	bugsnag.Configure(bugsnag.Configuration{APIKey: ...})
 type Handler struct {
 	next           slog.Handler
 	goa            *groupOrAttrs
-	notifyLevel    slog.Leveler
-	unhandledLevel slog.Leveler
+	config         *atomic.Pointer[handlerConfig]
 	notifiers      *NotifierWorkers
+	routeFn        func(ctx context.Context, r slog.Record) *NotifierWorkers
+	routeRules     []RouteRule
+	routed         *sync.Map // *NotifierWorkers returned by routeFn, shared by clones
+	forward        bool
+	tabNamer       TabNamer
+	replaceAttr    func(groups []string, a slog.Attr) slog.Attr
+	limits         *limits
+	valueEncoder   ValueEncoder
+	breadcrumbs    *breadcrumbs
+	userFromCtx    func(ctx context.Context) (bugsnag.User, bool)
+	ctxExtractors  []ContextExtractor
+	onNotify       []func(ctx context.Context, r slog.Record, err error)
+	beforeNotify   []func(event *bugsnag.Event)
+	bugsnagContext func(ctx context.Context, r slog.Record) string
 }
 
 var _ slog.Handler = &Handler{} // Assert conformance with interface
@@ -168,77 +567,186 @@ func NewHandler(next slog.Handler, opts *HandlerOptions) *Handler {
 	if opts == nil {
 		opts = &HandlerOptions{}
 	}
-	if opts.NotifyLevel == nil {
-		opts.NotifyLevel = slog.LevelError
-	}
-	if opts.UnhandledLevel == nil {
-		opts.UnhandledLevel = slog.LevelError + 4
-	}
 	if opts.Notifiers == nil {
 		opts.Notifiers = NewNotifierWorkers(nil)
 	}
+	if opts.TabNamer == nil {
+		opts.TabNamer = InnermostGroupTab
+	}
 
 	return &Handler{
 		next:           next,
-		notifyLevel:    opts.NotifyLevel,
-		unhandledLevel: opts.UnhandledLevel,
+		config:         newLiveConfig(Config{NotifyLevel: opts.NotifyLevel, UnhandledLevel: opts.UnhandledLevel, Redact: opts.Redact}),
 		notifiers:      opts.Notifiers,
+		routeFn:        opts.Route,
+		routeRules:     opts.RouteRules,
+		routed:         &sync.Map{},
+		forward:        opts.ForwardGroupsAndAttrs,
+		tabNamer:       opts.TabNamer,
+		replaceAttr:    opts.ReplaceAttr,
+		limits:         newLimits(opts.Limits),
+		valueEncoder:   opts.ValueEncoder,
+		breadcrumbs:    newBreadcrumbs(opts.Breadcrumbs),
+		userFromCtx:    opts.UserFromContext,
+		ctxExtractors:  opts.ContextExtractors,
+		onNotify:       opts.OnNotify,
+		beforeNotify:   opts.BeforeNotify,
+		bugsnagContext: opts.BugsnagContext,
 	}
 }
 
-// Enabled reports whether the next handler handles records at the given level.
+// Enabled reports whether the next handler handles records at the given level,
+// or whether records at the level are kept as breadcrumbs.
 // The handler ignores records whose level is lower.
 func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
-	return h.next.Enabled(ctx, level)
+	return h.next.Enabled(ctx, level) || h.breadcrumbs.keeps(level)
 }
 
 // Handle collects all attributes and groups, then passes the record and its attributes to the next handler.
+// If the handler has no groups or attributes of its own, or if it forwards them
+// to the next handler, the original record is passed along untouched.
 func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
-	// Collect all attributes from the record (which is the most recent attribute set).
+	notify := r.Level >= h.current().notifyLevel.Level()
+
+	// Put on the channel to be sent to bugsnag.
+	// Only capture what can't be recovered later (the record, the stack, and
+	// the breadcrumbs and scope so far); the workers will build the metadata
+	// and resolve the attribute values.
+	var nw *NotifierWorkers
+	if notify {
+		nw = h.route(ctx, r)
+	}
+	if nw != nil && !nw.closed() {
+		if len(h.onNotify) > 0 {
+			err := findError(h.collectAttrs(r))
+			for _, fn := range h.onNotify {
+				fn(ctx, r, err)
+			}
+		}
+
+		bug := bugRecord{
+			h:           h,
+			ctx:         ctx,
+			record:      r.Clone(),
+			breadcrumbs: h.breadcrumbs.snapshot(ctx),
+			scope:       scopeFromContext(ctx).snapshot(),
+			unhandled:   r.Level >= h.current().unhandledLevel.Level(),
+		}
+		if !h.errorHasStack(r) {
+			bug.stack = callers()
+		}
+		if nw.autoscale != nil {
+			bug.queued = time.Now()
+		}
+		// Unhandled bugs skip ahead of the others, in their own queue
+		queue := nw.bugsCh
+		if bug.unhandled {
+			queue = nw.priorityCh
+		}
+		bug.waiter = waiterFromContext(ctx)
+		if bug.waiter != nil {
+			bug.waiter.wg.Add(1)
+		}
+		nw.addPending()
+		select {
+		case queue <- bug:
+		default:
+			// The buffered channel is full, the workers can't keep up,
+			nw.donePending()
+			if bug.waiter != nil {
+				bug.waiter.wg.Done()
+			}
+			nw.dropped.Add(1)
+			if bug.unhandled {
+				nw.priorityDropped.Add(1)
+			}
+			h.logBufferFull(ctx, r.Message, r.PC)
+		}
+	}
+
+	if !notify {
+		// Keep lower-level records in the context's scope, if it has one
+		if s := scopeFromContext(ctx); s != nil {
+			s.add(h, r)
+		}
+
+		if h.breadcrumbs.keeps(r.Level) {
+			h.breadcrumbs.add(ctx, h, r)
+
+			// Enabled may have only let this record through to be kept as a breadcrumb
+			if !h.next.Enabled(ctx, r.Level) {
+				return nil
+			}
+		}
+	}
+
+	// Fast path: nothing to add to the record
+	if h.forward || h.goa == nil {
+		return h.next.Handle(ctx, r)
+	}
+
+	// Add all attributes to new record (because old record has all the old attributes as private members)
+	newR := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	newR.AddAttrs(h.collectAttrs(r)...)
+
+	// Pass off to the next handler
+	return h.next.Handle(ctx, newR)
+}
+
+// errorHasStack returns true if the error that will be sent to bugsnag, the
+// latest one in the record or the handler's attributes, already has a stack
+// trace, so that there is no need to capture one.
+func (h *Handler) errorHasStack(r slog.Record) bool {
+	var err error
+	r.Attrs(func(a slog.Attr) bool {
+		if found := findError([]slog.Attr{a}); found != nil {
+			err = found
+		}
+		return true
+	})
+	for g := h.goa; err == nil && g != nil; g = g.next {
+		err = findError(g.attrs)
+	}
+	return err != nil && hasStack(err)
+}
+
+// collectAttrs returns all attributes from the record, nested inside the
+// handler's groups and preceded by the handler's attributes.
+// It allocates a single slice per group level.
+func (h *Handler) collectAttrs(r slog.Record) []slog.Attr {
+	// Collect all attributes from the record (which is the most recent attribute set),
+	// at the end of a slice big enough to also hold the handler's attributes
+	// that were added since the last group was opened.
 	// These attributes are ordered from oldest to newest, and our collection will be too.
-	finalAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	finalAttrs := make([]slog.Attr, h.goa.attrsUntilGroup()+r.NumAttrs())
+	pos := len(finalAttrs) - r.NumAttrs()
+	idx := pos
 	r.Attrs(func(a slog.Attr) bool {
-		finalAttrs = append(finalAttrs, a)
+		finalAttrs[idx] = a
+		idx++
 		return true
 	})
 
-	// Iterate through the goa (group Or Attributes) linked list, which is ordered from newest to oldest
+	// Iterate through the goa (group Or Attributes) linked list, which is ordered
+	// from newest to oldest, so finalAttrs is filled in from back to front.
 	for g := h.goa; g != nil; g = g.next {
 		if g.group != "" {
-			// If a group, put all the previous attributes (the newest ones) in it
-			finalAttrs = []slog.Attr{{
+			// If a group, put all the previous attributes (the newest ones) in it,
+			// then start a new slice for the attributes outside of the group
+			group := slog.Attr{
 				Key:   g.group,
-				Value: slog.GroupValue(finalAttrs...),
-			}}
+				Value: slog.GroupValue(finalAttrs[pos:]...),
+			}
+			finalAttrs = make([]slog.Attr, g.next.attrsUntilGroup()+1)
+			pos = len(finalAttrs) - 1
+			finalAttrs[pos] = group
 		} else {
 			// Prepend to the front of finalAttrs, because finalAttrs is ordered from oldest to newest
-			finalAttrs = append(slices.Clip(g.attrs), finalAttrs...)
-		}
-	}
-
-	// Add all attributes to new record (because old record has all the old attributes as private members)
-	newR := &slog.Record{
-		Time:    r.Time,
-		Level:   r.Level,
-		Message: r.Message,
-		PC:      r.PC,
-	}
-
-	// Add attributes back in
-	newR.AddAttrs(finalAttrs...)
-
-	// Put on the channel to be sent to bugsnag
-	if newR.Level >= h.notifyLevel.Level() && !h.notifiers.closed() {
-		select {
-		case h.notifiers.bugsCh <- h.logToBug(ctx, newR.Time, newR.Level, newR.Message, newR.PC, finalAttrs):
-		default:
-			// The buffered channel is full, the workers can't keep up,
-			h.logBufferFull(ctx, newR.Message, newR.PC)
+			pos -= len(g.attrs)
+			copy(finalAttrs[pos:], g.attrs)
 		}
 	}
-
-	// Pass off to the next handler
-	return h.next.Handle(ctx, *newR)
+	return finalAttrs[pos:]
 }
 
 // WithGroup returns a new AppendHandler that still has h's attributes,
@@ -246,6 +754,9 @@ func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
 func (h *Handler) WithGroup(name string) slog.Handler {
 	h2 := *h
 	h2.goa = h2.goa.WithGroup(name)
+	if h.forward {
+		h2.next = h.next.WithGroup(name)
+	}
 	return &h2
 }
 
@@ -253,14 +764,29 @@ func (h *Handler) WithGroup(name string) slog.Handler {
 func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	h2 := *h
 	h2.goa = h2.goa.WithAttrs(attrs)
+	if h.forward {
+		h2.next = h.next.WithAttrs(attrs)
+	}
 	return &h2
 }
 
 // Close stops the handler from sending any new bugs after this point to bugsnag,
 // but it will continue to pass the log records to the next handler.
 // This call will block until all bugs currently queued have been sent.
+// The NotifierWorkers of the handler's RouteRules, and those returned by its
+// Route func, are closed too.
 func (h *Handler) Close() {
-	h.notifiers.Close()
+	for _, nw := range h.allNotifiers() {
+		nw.Close()
+	}
+}
+
+// Flush blocks until all bugs currently queued have been sent, without
+// closing the handler's NotifierWorkers, or those it routes bugs to.
+func (h *Handler) Flush() {
+	for _, nw := range h.allNotifiers() {
+		nw.Flush()
+	}
 }
 
 // logBufferFull sends a log message directly to the next handler to record
@@ -8,7 +8,6 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"strings"
-	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -37,7 +36,7 @@ func TestHandler(t *testing.T) {
 				"log": {
 					"time":   "2023-09-29T13:00:59Z",
 					"level":  "ERROR",
-					"source": "github.com/veqryn/slog-bugsnag.TestHandler:102",
+					"source": "github.com/veqryn/slog-bugsnag.TestHandler:101",
 					"msg":    "main message",
 					"with1":  "arg0",
 				},
@@ -124,18 +123,16 @@ func TestHandlerOverloaded(t *testing.T) {
 	defer svr.Close()
 
 	// Set the bugsnag config to send all communication to the test server
-	notifiers := &NotifierWorkers{
-		notifier: bugsnag.New(bugsnag.Configuration{
+	notifiers := NewNotifierWorkers(&NotifierOptions{
+		Notifier: bugsnag.New(bugsnag.Configuration{
 			Endpoints: bugsnag.Endpoints{
 				Notify:   svr.URL,
 				Sessions: svr.URL,
 			},
 		}),
-		bugsCh:   make(chan bugRecord, 1),
-		workerWG: sync.WaitGroup{},
-		isClosed: atomic.Bool{},
-	}
-	notifiers.start(1)
+		MaxNotifierConcurrency: 1,
+		QueueSize:              1,
+	})
 
 	tester := &testHandler{}
 	h := NewHandler(tester, &HandlerOptions{Notifiers: notifiers})
@@ -165,3 +162,40 @@ func TestHandlerOverloaded(t *testing.T) {
 		t.Error("Expected a log line about bug buffer full; Got:", tester.Records)
 	}
 }
+
+func TestNotifierWorkersPriority(t *testing.T) {
+	t.Parallel()
+
+	gate := make(chan struct{})
+	svr := newGatedBugsnagTestServer(t, gate)
+	nw := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 1, QueueSize: 2, PriorityQueueSize: 1})
+	logger := slog.New(NewHandler(&testHandler{}, &HandlerOptions{Notifiers: nw, NotifyLevel: slog.LevelInfo, UnhandledLevel: slog.LevelWarn}))
+
+	// The only worker is stuck sending the first bug
+	logger.Info("first")
+	waitForStats(t, "first", nw, func(stats NotifierStats) bool { return stats.Queued == 0 })
+
+	// A burst of handled bugs fills their queue, but not the priority queue
+	for i := 0; i < 3; i++ {
+		logger.Info("handled", "i", i)
+	}
+	logger.Warn("unhandled")
+	logger.Error("dropped unhandled")
+
+	stats := nw.Stats()
+	if stats.Queued != 3 || stats.PriorityQueued != 1 || stats.Dropped != 2 || stats.PriorityDropped != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	close(gate)
+	nw.Close()
+
+	// The unhandled bug skips ahead of the handled ones queued before it
+	var contexts []string
+	for _, event := range svr.Events() {
+		contexts = append(contexts, event.Context)
+	}
+	if strings.Join(contexts, ",") != "first,unhandled,handled,handled" {
+		t.Error("Unexpected order of events:", contexts)
+	}
+}
@@ -37,3 +37,13 @@ func (g *groupOrAttrs) WithAttrs(attrs []slog.Attr) *groupOrAttrs {
 		next:  g,
 	}
 }
+
+// attrsUntilGroup returns the number of attributes in the linked list before
+// the first group is reached. Safe to call on a nil groupOrAttrs.
+func (g *groupOrAttrs) attrsUntilGroup() int {
+	var n int
+	for ; g != nil && g.group == ""; g = g.next {
+		n += len(g.attrs)
+	}
+	return n
+}
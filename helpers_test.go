@@ -3,8 +3,16 @@ package slogbugsnag
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"io"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
 	"time"
+
+	"github.com/bugsnag/bugsnag-go/v2"
 )
 
 var defaultTime = time.Date(2023, 9, 29, 13, 0, 59, 0, time.UTC)
@@ -67,3 +75,91 @@ func (h *testHandler) marshalJSON(recordIndex int) ([]byte, error) {
 	}
 	return buf.Bytes(), nil
 }
+
+// bugsnagTestServer is a fake bugsnag server that records all events it receives
+type bugsnagTestServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	events   []bugsnagEvent
+	sessions int
+}
+
+// newBugsnagTestServer starts a fake bugsnag server, which is closed when the test ends
+func newBugsnagTestServer(t *testing.T) *bugsnagTestServer {
+	return newGatedBugsnagTestServer(t, nil)
+}
+
+// newGatedBugsnagTestServer starts a fake bugsnag server that doesn't respond
+// until the gate is closed, unless the gate is nil
+func newGatedBugsnagTestServer(t *testing.T, gate <-chan struct{}) *bugsnagTestServer {
+	s := &bugsnagTestServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		if gate != nil {
+			<-gate
+		}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error("Unable to read body:", err)
+		}
+
+		var payload struct {
+			bugsnagPayload
+			SessionCounts []struct {
+				SessionsStarted int `json:"sessionsStarted"`
+			} `json:"sessionCounts"`
+		}
+		if err = json.Unmarshal(b, &payload); err != nil {
+			t.Error("Unable to unmarshal json to bugsnag payload")
+		}
+
+		s.mu.Lock()
+		s.events = append(s.events, payload.Events...)
+		for _, counts := range payload.SessionCounts {
+			s.sessions += counts.SessionsStarted
+		}
+		s.mu.Unlock()
+		if len(payload.SessionCounts) > 0 {
+			w.WriteHeader(http.StatusAccepted) // The sessions endpoint responds 202
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Events returns all events received so far
+func (s *bugsnagTestServer) Events() []bugsnagEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]bugsnagEvent(nil), s.events...)
+}
+
+// Sessions returns the number of sessions received so far
+func (s *bugsnagTestServer) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions
+}
+
+// Notifier returns a bugsnag notifier that sends all communication to the test server
+func (s *bugsnagTestServer) Notifier() *bugsnag.Notifier {
+	return bugsnag.New(bugsnag.Configuration{
+		Endpoints: bugsnag.Endpoints{
+			Notify:   s.URL,
+			Sessions: s.URL,
+		},
+	})
+}
+
+// waitForStats waits until the stats pass the check
+func waitForStats(t *testing.T, name string, nw *NotifierWorkers, check func(stats NotifierStats) bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if check(nw.Stats()) {
+			return
+		}
+	}
+	t.Fatalf("%s: Unexpected stats: %+v", name, nw.Stats())
+}
@@ -0,0 +1,156 @@
+package slogbugsnag
+
+import (
+	"bufio"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+)
+
+// HTTPMiddlewareOptions are options for HTTPMiddleware
+type HTTPMiddlewareOptions struct {
+	// Logger logs the panics and server error responses. Its handler should be
+	// a Handler, or lead to one, so that they are sent to bugsnag through the
+	// handler's NotifierWorkers.
+	// If nil, slog.Default() is used.
+	Logger *slog.Logger
+
+	// PanicLevel is the level that panics are logged at. It should be at least
+	// the handler's UnhandledLevel, so that panics are reported as unhandled.
+	// If nil, the handler's UnhandledLevel is used if the Logger's handler is
+	// a Handler, otherwise slog.LevelError + 4, the default UnhandledLevel.
+	PanicLevel slog.Leveler
+
+	// Repanic, if true, panics again with the same value after a panic has been
+	// logged, so that the http.Server (or any other middleware) also sees it.
+	// If false, the panic is recovered, and a 500 Internal Server Error is
+	// written if the response has not been started yet.
+	Repanic bool
+
+	// LogServerErrors, if true, logs every response with a 5xx status code
+	// at the ServerErrorLevel.
+	LogServerErrors bool
+
+	// ServerErrorLevel is the level that server error responses are logged at.
+	// If nil, slog.LevelError is used.
+	ServerErrorLevel slog.Leveler
+
+	// Sessions starts a bugsnag session for each request.
+	// If nil, bugsnag's global session tracker is used, if the bugsnag config
+	// AutoCaptureSessions is on.
+	Sessions *SessionTracker
+}
+
+// HTTPMiddleware wraps the http.Handler, so that for each request it:
+//   - Starts a bugsnag session, with the options' Sessions tracker.
+//   - Attaches the request to the context, for bugsnag to include in any bugs
+//     logged with the request's context.
+//   - Recovers panics, and logs them with their stack trace as unhandled bugs.
+//   - Logs responses with a 5xx status code, if LogServerErrors is on.
+//
+// Everything is logged through the options' Logger, and so is sent to bugsnag
+// by the same NotifierWorkers as the rest of the logs.
+// If opts is nil, the default options are used.
+func HTTPMiddleware(h http.Handler, opts *HTTPMiddlewareOptions) http.Handler {
+	if opts == nil {
+		opts = &HTTPMiddlewareOptions{}
+	}
+	if opts.ServerErrorLevel == nil {
+		opts.ServerErrorLevel = slog.LevelError
+	}
+	logger := func() *slog.Logger {
+		if opts.Logger != nil {
+			return opts.Logger
+		}
+		return slog.Default()
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		ctx = opts.Sessions.StartSession(ctx)
+		ctx = bugsnag.AttachRequestData(ctx, r)
+		r = r.WithContext(ctx)
+		rw := &statusRecorder{ResponseWriter: w}
+
+		defer func() {
+			if rec := recover(); rec != nil {
+				// The http.Server expects this panic, to abort the response
+				if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(rec)
+				}
+
+				logPanic(ctx, logger(), opts.PanicLevel, newPanicError(rec))
+
+				if opts.Repanic {
+					panic(rec)
+				}
+				if rw.status == 0 {
+					http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+				return
+			}
+
+			if opts.LogServerErrors && rw.status >= 500 {
+				logger().Log(ctx, opts.ServerErrorLevel.Level(), "http server error response",
+					slog.Int("status", rw.status), slog.String("method", r.Method), slog.String("path", r.URL.Path))
+			}
+		}()
+
+		h.ServeHTTP(rw, r)
+	})
+}
+
+// statusRecorder records the status code written to the response.
+// It passes through flushing, hijacking, and pushing to the underlying
+// http.ResponseWriter, returning http.ErrNotSupported if it doesn't support them.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the final status code, then writes it to the response
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 && status >= 200 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records an implicit 200 OK status code, then writes to the response
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the response, if the underlying http.ResponseWriter supports it
+func (w *statusRecorder) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack lets the handler take over the connection, such as for websockets,
+// if the underlying http.ResponseWriter supports it
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Push initiates an HTTP/2 server push, if the underlying http.ResponseWriter supports it
+func (w *statusRecorder) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := w.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for http.ResponseController
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,138 @@
+package slogbugsnag
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	t.Parallel()
+
+	svr := newBugsnagTestServer(t)
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 1})
+	logger := slog.New(NewHandler(&testHandler{}, &HandlerOptions{Notifiers: notifiers}))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("oh no")
+	})
+	mux.HandleFunc("/unavailable", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	h := HTTPMiddleware(mux, &HTTPMiddlewareOptions{Logger: logger, LogServerErrors: true})
+
+	for path, expectedStatus := range map[string]int{"/panic": 500, "/unavailable": 503, "/ok": 200} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil))
+		if w.Code != expectedStatus {
+			t.Errorf("Expected %s to respond %d; Got: %d", path, expectedStatus, w.Code)
+		}
+	}
+
+	notifiers.Close()
+
+	events := svr.Events()
+	if len(events) != 2 {
+		t.Fatal("Expected 2 bugsnag events; Got:", len(events))
+	}
+
+	for _, event := range events {
+		switch event.Context {
+		case "panic: oh no":
+			if !event.Unhandled || event.Request.URL != "http://example.com/panic" {
+				t.Errorf("%#+v\n", event)
+			}
+			if len(event.Exceptions) == 0 || event.Exceptions[0].ErrorClass != "*slogbugsnag.PanicError" {
+				t.Errorf("%#+v\n", event.Exceptions)
+			}
+
+		case "http server error response":
+			if event.Unhandled || event.Request.URL != "http://example.com/unavailable" || event.MetaData["log"]["status"] != float64(503) {
+				t.Errorf("%#+v\n", event)
+			}
+
+		default:
+			t.Error("Unexpected event:", event.Context)
+		}
+	}
+}
+
+func TestHTTPMiddlewareRepanic(t *testing.T) {
+	t.Parallel()
+
+	svr := newBugsnagTestServer(t)
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 1})
+	logger := slog.New(NewHandler(&testHandler{}, &HandlerOptions{Notifiers: notifiers}))
+
+	h := HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}), &HTTPMiddlewareOptions{Logger: logger, Repanic: true})
+
+	func() {
+		defer func() {
+			if rec := recover(); rec != http.ErrAbortHandler {
+				t.Error("Expected the abort panic to be passed on; Got:", rec)
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	notifiers.Close()
+
+	if events := svr.Events(); len(events) != 0 {
+		t.Error("Expected aborted responses not to be sent to bugsnag; Got:", len(events))
+	}
+}
+
+func TestHTTPMiddlewareHijack(t *testing.T) {
+	t.Parallel()
+
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: newBugsnagTestServer(t).Notifier()})
+	defer notifiers.Close()
+	logger := slog.New(NewHandler(&testHandler{}, &HandlerOptions{Notifiers: notifiers}))
+	h := HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			t.Error("Expected an http.Hijacker")
+			return
+		}
+		conn, rw, err := hijacker.Hijack()
+		if err != nil {
+			t.Error("Unable to hijack:", err)
+			return
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		_ = rw.Flush()
+	}), &HTTPMiddlewareOptions{Logger: logger})
+
+	svr := httptest.NewServer(h)
+	defer svr.Close()
+	resp, err := http.Get(svr.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "hijacked" {
+		t.Error("Unexpected body:", string(body))
+	}
+
+	// The recorder supports neither
+	h = HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := w.(http.Hijacker).Hijack(); !errors.Is(err, http.ErrNotSupported) {
+			t.Error("Expected http.ErrNotSupported; Got:", err)
+		}
+		if err := w.(http.Pusher).Push("/style.css", nil); !errors.Is(err, http.ErrNotSupported) {
+			t.Error("Expected http.ErrNotSupported; Got:", err)
+		}
+	}), &HTTPMiddlewareOptions{Logger: logger})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
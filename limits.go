@@ -0,0 +1,317 @@
+package slogbugsnag
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+	bserrors "github.com/bugsnag/bugsnag-go/v2/errors"
+)
+
+// truncatedDepth replaces values nested deeper than the MaxDepth limit
+const truncatedDepth = "…[truncated: too deep]"
+
+// truncatedKey is the log tab key that notes any truncation of the event.
+// A number is appended (ex: "truncated (2)") if an attribute already uses it.
+const truncatedKey = "truncated"
+
+// LimitOptions are limits on the size of the events sent to bugsnag.
+// Bugsnag rejects events that are too large (about 1MB), so a single log with
+// a large attribute, such as a request body or a big slice, could otherwise
+// cause the whole bug to be dropped.
+// Truncated values are marked (ex: "…[truncated 12KB]"), and a "truncated"
+// entry is added to the log tab, counting what was truncated.
+// If an attribute already has the "truncated" key, the entry is named
+// "truncated (2)" instead.
+// Zero values mean no limit.
+type LimitOptions struct {
+	// MaxStringLength is the maximum length in bytes of each string value,
+	// including strings nested inside maps, slices, and structs.
+	MaxStringLength int
+
+	// MaxAttrsPerTab is the maximum number of attributes in each tab.
+	// Attributes past the limit are dropped.
+	MaxAttrsPerTab int
+
+	// MaxDepth is the maximum nesting depth of maps, slices, arrays, structs,
+	// and groups inside each attribute value. Deeper values are replaced.
+	MaxDepth int
+
+	// MaxEventSize is the maximum size in bytes of the whole event, once
+	// encoded to json: the message, errors and their stacktraces, user,
+	// request, and all the metadata tabs, including the breadcrumbs.
+	// If it is exceeded, the largest metadata values are replaced until the
+	// event fits. If the event can't fit even without its metadata, the
+	// error's deepest causes are dropped, then the message and context are
+	// truncated, then the outermost stack frames are dropped.
+	// It is applied after the BeforeNotify callbacks and redaction.
+	MaxEventSize int
+}
+
+// limits applies a handler's limit options to the metadata
+type limits LimitOptions
+
+// newLimits returns limits for the options, or nil if there are no options
+func newLimits(opts *LimitOptions) *limits {
+	if opts == nil {
+		return nil
+	}
+	l := limits(*opts)
+	return &l
+}
+
+// enabled returns true if there are any limits
+func (l *limits) enabled() bool {
+	return l != nil && (l.MaxStringLength > 0 || l.MaxAttrsPerTab > 0 || l.MaxDepth > 0 || l.MaxEventSize > 0)
+}
+
+// tooDeep returns true if the depth is over the MaxDepth limit
+func (l *limits) tooDeep(depth int) bool {
+	return l != nil && l.MaxDepth > 0 && depth > l.MaxDepth
+}
+
+// tooManyAttrs returns true if a tab with this many attributes is full
+func (l *limits) tooManyAttrs(count int) bool {
+	return l != nil && l.MaxAttrsPerTab > 0 && count >= l.MaxAttrsPerTab
+}
+
+// truncations counts what was truncated in a bug
+type truncations struct {
+	strings int
+	attrs   int
+	depth   int
+	causes  int
+	message int
+	context int
+	frames  int
+	values  int
+
+	// noteKey is the log tab key of the note counting the truncations, once added
+	noteKey string
+}
+
+// truncateString truncates the string to the MaxStringLength limit,
+// on a rune boundary, and marks how much was removed.
+func (b *metaDataBuilder) truncateString(s string) string {
+	if b.limits == nil || b.limits.MaxStringLength <= 0 || len(s) <= b.limits.MaxStringLength {
+		return s
+	}
+	cut := b.limits.MaxStringLength
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	b.truncated.strings++
+	return s[:cut] + truncatedMarker(len(s)-cut)
+}
+
+// limitEventSize is a bugsnag callback that trims the event until its json
+// encoded size fits inside the MaxEventSize limit. The largest metadata values
+// are replaced first. The causes, message, context, and stacktrace are only shortened
+// if the event can't fit without doing so.
+// Values are compared by their encoded size, and ties are broken by tab and
+// key name, so that the result is deterministic.
+func (b *metaDataBuilder) limitEventSize(event *bugsnag.Event) {
+	// Leave room for the parts of the payload that can't be trimmed (the app,
+	// device, notifier, session, and severity), and for noting the truncation
+	const overhead = 1024 + 128
+	budget := b.limits.MaxEventSize - overhead
+	size := eventSizeWithoutMetadata(event)
+
+	// The deepest causes are dropped first. The rest of the chain is copied,
+	// so that the logged errors are not changed.
+	if size > budget && event.Error != nil && event.Error.Cause != nil {
+		var causes []*bserrors.Error
+		for cause := event.Error.Cause; cause != nil; cause = cause.Cause {
+			causes = append(causes, cause)
+		}
+		for size > budget && len(causes) > 0 {
+			size -= causeSize(causes[len(causes)-1])
+			causes = causes[:len(causes)-1]
+			b.truncated.causes++
+		}
+		var next *bserrors.Error
+		for i := len(causes) - 1; i >= 0; i-- {
+			cause := *causes[i]
+			cause.Cause = next
+			next = &cause
+		}
+		e := *event.Error
+		e.Cause = next
+		event.Error = &e
+	}
+	// The context defaults to the message, so both may need to be shortened
+	for _, field := range []struct {
+		value *string
+		count *int
+	}{{&event.Message, &b.truncated.message}, {&event.Context, &b.truncated.context}} {
+		if over := size - budget; over > 0 && len(*field.value) > 0 {
+			before := jsonSize(*field.value)
+			*field.value = truncateToFit(*field.value, over)
+			size += jsonSize(*field.value) - before
+			*field.count++
+		}
+	}
+	// The outermost frames, furthest from the log call, are dropped first
+	for size > budget && len(event.Stacktrace) > 1 {
+		last := len(event.Stacktrace) - 1
+		size -= jsonSize(event.Stacktrace[last]) + 1
+		event.Stacktrace = event.Stacktrace[:last]
+		b.truncated.frames++
+	}
+
+	type entry struct {
+		tab, key string
+		size     int
+	}
+	var entries []entry
+	total := 2 // {}
+	for tab, m := range event.MetaData {
+		total += len(tab) + 6 // "tab":{},
+		for key, value := range m {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				// Bugsnag will sanitize it, so there is no way to know its size
+				continue
+			}
+			entries = append(entries, entry{tab: tab, key: key, size: len(encoded)})
+			total += len(key) + len(encoded) + 4 // "key":value,
+		}
+	}
+
+	for size+total > budget {
+		largest := -1
+		for i, e := range entries {
+			if largest < 0 || e.size > entries[largest].size ||
+				(e.size == entries[largest].size && (e.tab < entries[largest].tab ||
+					(e.tab == entries[largest].tab && e.key < entries[largest].key))) {
+				largest = i
+			}
+		}
+		if largest < 0 {
+			break
+		}
+
+		e := entries[largest]
+		marker := truncatedMarker(e.size)
+		if len(marker)+2 >= e.size {
+			// Nothing left that would get smaller by being replaced
+			break
+		}
+		event.MetaData[e.tab][e.key] = marker
+		b.truncated.values++
+		total -= e.size - (len(marker) + 2)
+		entries = append(entries[:largest], entries[largest+1:]...)
+	}
+
+	b.noteTruncation(event.MetaData)
+}
+
+// truncateToFit shortens the string by at least over bytes, plus the size of
+// the marker, on a rune boundary, and marks how much was removed
+func truncateToFit(s string, over int) string {
+	cut := max(len(s)-over-32, 0) // 32 leaves room for the marker
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + truncatedMarker(len(s)-cut)
+}
+
+// eventSizeWithoutMetadata estimates the json encoded size of the parts of
+// the event other than its metadata, including the stacktraces of its causes
+func eventSizeWithoutMetadata(event *bugsnag.Event) int {
+	size := jsonSize(event.ErrorClass) + jsonSize(event.Message) + jsonSize(event.Context) +
+		jsonSize(event.GroupingHash) + jsonSize(event.User) + jsonSize(event.Request)
+	for _, frame := range event.Stacktrace {
+		size += jsonSize(frame) + 1
+	}
+	if event.Error != nil {
+		for cause := event.Error.Cause; cause != nil; cause = cause.Cause {
+			size += causeSize(cause)
+		}
+	}
+	return size
+}
+
+// causeSize estimates the json encoded size of the exception for the cause
+func causeSize(cause *bserrors.Error) int {
+	size := jsonSize(cause.TypeName()) + jsonSize(cause.Error()) + 64 // The keys and punctuation
+	for _, frame := range cause.StackFrames() {
+		size += jsonSize(bugsnag.StackFrame{Method: frame.Name, File: frame.File, LineNumber: frame.LineNumber}) + 1
+	}
+	return size
+}
+
+// jsonSize returns the size of the value once encoded to json,
+// or zero if it can't be encoded
+func jsonSize(v any) int {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(encoded)
+}
+
+// noteTruncation counts what was truncated in an entry in the log tab.
+// The entry's key is "truncated", unless an attribute already has that key.
+// It can be called again, to update the counts in the entry.
+func (b *metaDataBuilder) noteTruncation(md bugsnag.MetaData) {
+	note := map[string]any{}
+	if b.truncated.strings > 0 {
+		note["strings"] = b.truncated.strings
+	}
+	if b.truncated.attrs > 0 {
+		note["attributes"] = b.truncated.attrs
+	}
+	if b.truncated.depth > 0 {
+		note["depth"] = b.truncated.depth
+	}
+	if b.truncated.causes > 0 {
+		note["causes"] = b.truncated.causes
+	}
+	if b.truncated.message > 0 {
+		note["message"] = b.truncated.message
+	}
+	if b.truncated.context > 0 {
+		note["context"] = b.truncated.context
+	}
+	if b.truncated.frames > 0 {
+		note["stackFrames"] = b.truncated.frames
+	}
+	if b.truncated.values > 0 {
+		note["eventSize"] = b.truncated.values
+	}
+	if len(note) == 0 {
+		return
+	}
+
+	if b.truncated.noteKey == "" {
+		key := truncatedKey
+		for i := 2; ; i++ {
+			if _, ok := md[logTab][key]; !ok {
+				break
+			}
+			key = truncatedKey + " (" + strconv.Itoa(i) + ")"
+		}
+		b.truncated.noteKey = key
+	}
+	md.Add(logTab, b.truncated.noteKey, note)
+}
+
+// truncatedMarker returns a marker for a truncated value of the given size
+func truncatedMarker(size int) string {
+	return "…[truncated " + formatSize(size) + "]"
+}
+
+// formatSize returns the size in bytes in a human-readable format
+func formatSize(size int) string {
+	switch {
+	case size < 1024:
+		return fmt.Sprintf("%dB", size)
+	case size < 1024*1024:
+		return fmt.Sprintf("%dKB", (size+1023)/1024)
+	default:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1024*1024))
+	}
+}
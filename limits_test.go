@@ -0,0 +1,196 @@
+package slogbugsnag
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+)
+
+func TestLimits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		limits   LimitOptions
+		attrs    []slog.Attr
+		expected bugsnag.MetaData
+	}{
+		{
+			name:   "MaxStringLength",
+			limits: LimitOptions{MaxStringLength: 5},
+			attrs: []slog.Attr{
+				slog.String("short", "abc"),
+				slog.String("long", strings.Repeat("a", 2000)),
+				slog.String("runes", "aaaa€€"),
+				slog.Any("nested", map[string][]string{"k": {"abcdefg"}}),
+				slog.String("truncated", "user"),
+			},
+			expected: bugsnag.MetaData{
+				"log": {
+					"short":         "abc",
+					"long":          "aaaaa…[truncated 2KB]",
+					"runes":         "aaaa…[truncated 6B]",
+					"nested":        map[string]any{"k": []any{"abcde…[truncated 2B]"}},
+					"truncated":     "user", // The note doesn't replace the attribute
+					"truncated (2)": map[string]any{"strings": 3},
+				},
+			},
+		},
+		{
+			name:   "MaxAttrsPerTab",
+			limits: LimitOptions{MaxAttrsPerTab: 2},
+			attrs: []slog.Attr{
+				slog.Int("a", 1),
+				slog.Int("b", 2),
+				slog.Int("c", 3),
+				slog.Int("a", 4),
+				slog.Group("g", slog.Int("d", 5), slog.Int("e", 6), slog.Int("f", 7)),
+			},
+			expected: bugsnag.MetaData{
+				"log": {
+					"a":         int64(4),
+					"b":         int64(2),
+					"truncated": map[string]any{"attributes": 2},
+				},
+				"g": {
+					"d": int64(5),
+					"e": int64(6),
+				},
+			},
+		},
+		{
+			name:   "MaxDepth",
+			limits: LimitOptions{MaxDepth: 2},
+			attrs: []slog.Attr{
+				slog.Any("one", []int{1}),
+				slog.Any("two", map[string][]int{"k": {1}}),
+				slog.Any("three", map[string]map[string][]int{"k": {"k": {1}}}),
+			},
+			expected: bugsnag.MetaData{
+				"log": {
+					"one":       []any{1},
+					"two":       map[string]any{"k": []any{1}},
+					"three":     map[string]any{"k": map[string]any{"k": "…[truncated: too deep]"}},
+					"truncated": map[string]any{"depth": 1},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				notifiers: &NotifierWorkers{notifier: bugsnag.New()},
+				limits:    newLimits(&tc.limits),
+			}
+			b := h.newMetaDataBuilder()
+			b.accumulateRawData(nil, tc.attrs)
+			b.finish()
+
+			if !reflect.DeepEqual(b.md, tc.expected) {
+				t.Errorf("%#+v\n", b.md)
+			}
+		})
+	}
+}
+
+func TestMaxEventSize(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var sizes []int
+	var payloads []bugsnagPayload
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		var payload bugsnagPayload
+		if err := json.Unmarshal(b, &payload); err != nil {
+			t.Error("Unable to unmarshal json to bugsnag payload")
+		}
+		mu.Lock()
+		sizes = append(sizes, len(b))
+		payloads = append(payloads, payload)
+		mu.Unlock()
+	}))
+	defer svr.Close()
+
+	const maxEventSize = 8 * 1024
+	notifiers := NewNotifierWorkers(&NotifierOptions{
+		Notifier: bugsnag.New(bugsnag.Configuration{
+			APIKey:    "1234567890abcdef1234567890abcdef",
+			Endpoints: bugsnag.Endpoints{Notify: svr.URL, Sessions: svr.URL},
+		}),
+		MaxNotifierConcurrency: 1,
+	})
+	logger := slog.New(NewHandler(&testHandler{}, &HandlerOptions{
+		Notifiers: notifiers,
+		Limits:    &LimitOptions{MaxEventSize: maxEventSize},
+	}))
+
+	// The largest metadata values are replaced first
+	logger.Error("big attrs",
+		"small", "abc",
+		"big1", strings.Repeat("a", 7000),
+		"big2", strings.Repeat("b", 6000),
+		slog.Group("g", "big3", strings.Repeat("c", 1000)),
+	)
+
+	// The message is only truncated if the event can't fit without doing so
+	logger.Error(strings.Repeat("m", 20000))
+
+	notifiers.Close()
+
+	if len(payloads) != 2 {
+		t.Fatal("Expected 2 bugsnag payloads; Got:", len(payloads))
+	}
+	for i, size := range sizes {
+		if size > maxEventSize {
+			t.Errorf("Expected payload %d to fit in %d bytes; Got: %d", i, maxEventSize, size)
+		}
+	}
+
+	md := payloads[0].Events[0].MetaData
+	if md["log"]["small"] != "abc" || md["log"]["big1"] != "…[truncated 7KB]" || md["log"]["big2"] != "…[truncated 6KB]" ||
+		md["g"]["big3"] != strings.Repeat("c", 1000) || !reflect.DeepEqual(md["log"]["truncated"], map[string]any{"eventSize": float64(2)}) {
+		t.Errorf("%#+v\n", md)
+	}
+
+	event := payloads[1].Events[0]
+	if msg := event.Exceptions[0].Message; !strings.HasSuffix(msg, "]") || !strings.Contains(msg, "…[truncated ") || len(msg) > maxEventSize {
+		t.Error("Expected the message to be truncated; Got:", len(msg))
+	}
+	if len(event.Exceptions) != 1 {
+		t.Error("Expected the cause, with the same long message, to be dropped; Got:", len(event.Exceptions))
+	}
+	if note, _ := event.MetaData["log"]["truncated"].(map[string]any); note["causes"] != float64(1) ||
+		note["message"] != float64(1) || note["context"] != float64(1) || note["eventSize"] == nil {
+		t.Errorf("%#+v\n", event.MetaData["log"])
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	t.Parallel()
+
+	for size, expected := range map[int]string{
+		0:               "0B",
+		1023:            "1023B",
+		1024:            "1KB",
+		12 * 1024:       "12KB",
+		12*1024 + 1:     "13KB",
+		3 * 1024 * 1024: "3.0MB",
+	} {
+		if actual := formatSize(size); actual != expected {
+			t.Errorf("formatSize(%d) expected %q; Got: %q", size, expected, actual)
+		}
+	}
+}
@@ -2,10 +2,8 @@ package slogbugsnag
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
 	"runtime"
-	"strings"
 	"time"
 
 	"github.com/bugsnag/bugsnag-go/v2"
@@ -63,110 +61,224 @@ func (email Email) BugsnagUserEmail() string {
 	return string(email)
 }
 
-// bug type contains everything needed to be sent off to bugsnag, preformatted
-type bugRecord struct {
-	err     error
-	rawData []any
+// bugsnagContext is a sentinel interface that gives you an option to
+// customize the bugsnag context of a bug
+type bugsnagContext interface {
+	BugsnagContext() string
 }
 
-// logToBug creates and formats a bug, from a log record and attributes.
-// The level of the error should be checked if sufficient or not before calling.
-func (h *Handler) logToBug(ctx context.Context, t time.Time, lvl slog.Level, msg string, pc uintptr, attrs []slog.Attr) bugRecord {
-	// Do we report this bugsnag as unhandled or handled?
-	var unhandled bool
-	if lvl >= h.unhandledLevel.Level() {
-		unhandled = true
-	}
+var _ bugsnagContext = Context("") // Validate implements interface
 
-	// Format the log source line
-	frameStack := runtime.CallersFrames([]uintptr{pc})
-	frame, _ := frameStack.Next()
-	source := fmt.Sprintf("%s:%d", frame.Function, frame.Line)
+// Context is a string that, if used as a log attribute value, will be used as
+// the bugsnag context of the bug, which is shown as its title in bugsnag,
+// such as an HTTP route, job name, or gRPC method.
+// The log message is still sent, in the log tab.
+type Context string
 
-	// Find the errors and bugsnag.User's in the log attributes.
-	// Create MetaData for all the other information in the log.
-	var errForBugsnag error
-	user := bugsnag.User{}
-	md := bugsnag.MetaData{}
-	h.accumulateRawData(&errForBugsnag, &user, md, "log", attrs)
+// BugsnagContext returns the bugsnag context
+func (c Context) BugsnagContext() string {
+	return string(c)
+}
 
-	// Add in the log record info
-	md.Add("log", "time", t.Format(time.RFC3339Nano))
-	md.Add("log", "level", lvl.String())
-	md.Add("log", "msg", msg)
-	md.Add("log", "source", source)
+// bugsnagContextCtxKey is the context key for a bugsnag context string
+type bugsnagContextCtxKey struct{}
 
-	// Ensure the error is not nil and has a stack trace
-	errForBugsnag = newErrorWithStack(errForBugsnag, msg, pc)
+// ContextWithBugsnagContext returns a copy of the context with the bugsnag
+// context string, to be used as the bugsnag context of any bug logged with the
+// context, unless a log attribute of type [Context] overrides it.
+func ContextWithBugsnagContext(ctx context.Context, bugsnagContext string) context.Context {
+	return context.WithValue(ctx, bugsnagContextCtxKey{}, bugsnagContext)
+}
 
-	// The order matters
-	rawData := []any{
-		ctx,
-		bugsnag.Context{String: msg},
-		bugsnag.HandledState{Unhandled: unhandled},
-		bsSeverity(lvl), // Must come after HandledState
-		md,
+// bugContext returns the bugsnag context for the bug, from the first one set of:
+// a log attribute of type Context, ContextWithBugsnagContext, the handler's
+// BugsnagContext option, and the log message.
+func (h *Handler) bugContext(ctx context.Context, r slog.Record, fromAttrs string) string {
+	if fromAttrs != "" {
+		return fromAttrs
 	}
-	if user.Id != "" || user.Name != "" || user.Email != "" {
-		rawData = append(rawData, user)
+	if ctx != nil {
+		if s, _ := ctx.Value(bugsnagContextCtxKey{}).(string); s != "" {
+			return s
+		}
+	}
+	if h.bugsnagContext != nil {
+		if s := h.bugsnagContext(ctx, r); s != "" {
+			return s
+		}
+	}
+	return r.Message
+}
+
+// userCtxKey is the context key for a bugsnag.User
+type userCtxKey struct{}
+
+// ContextWithUser returns a copy of the context with the user, to be filled
+// in on the [bugsnag.User] of any bug logged with the context.
+// User log attributes override the context's user, field by field.
+func ContextWithUser(ctx context.Context, user bugsnag.User) context.Context {
+	return context.WithValue(ctx, userCtxKey{}, user)
+}
+
+// userFromContext returns the user in the context, from ContextWithUser, overridden
+// field by field by the user from the handler's UserFromContext, if set
+func (h *Handler) userFromContext(ctx context.Context) bugsnag.User {
+	if ctx == nil {
+		return bugsnag.User{}
+	}
+	user, _ := ctx.Value(userCtxKey{}).(bugsnag.User)
+	if h.userFromCtx != nil {
+		if ctxUser, ok := h.userFromCtx(ctx); ok {
+			user = mergeUser(user, ctxUser)
+		}
+	}
+	return user
+}
+
+// mergeUser returns the base user, with its fields overridden by the non-empty
+// fields of the override
+func mergeUser(base, override bugsnag.User) bugsnag.User {
+	if override.Id != "" {
+		base.Id = override.Id
+	}
+	if override.Name != "" {
+		base.Name = override.Name
 	}
+	if override.Email != "" {
+		base.Email = override.Email
+	}
+	return base
+}
 
-	return bugRecord{err: errForBugsnag, rawData: rawData}
+// extractContextAttrs returns the attributes from all the context extractors
+func (h *Handler) extractContextAttrs(ctx context.Context) []slog.Attr {
+	var attrs []slog.Attr
+	for _, extractor := range h.ctxExtractors {
+		attrs = append(attrs, extractor(ctx)...)
+	}
+	return attrs
 }
 
-// accumulateRawData recursively iterates through all attributes and turns them
-// into [bugsnag.MetaData] tabs. The log tab is used for all root-level attributes.
-// All attributes in groups get their own tab, named after the group.
-// Attribute values are redacted based on the notifier config ParamsFilters.
-// accumulateRawData also finds the latest [error] and [bugsnag.User].
-func (h *Handler) accumulateRawData(errForBugsnag *error, user *bugsnag.User, md bugsnag.MetaData, tab string, attrs []slog.Attr) {
+// findError returns the latest error among the attributes, including those
+// inside groups, the same way the bug's error is found, or nil if there is none
+func findError(attrs []slog.Attr) error {
+	var found error
 	for _, attr := range attrs {
 		if attr.Value.Kind() == slog.KindGroup {
-			h.accumulateRawData(errForBugsnag, user, md, attr.Key, attr.Value.Group())
+			if err := findError(attr.Value.Group()); err != nil {
+				found = err
+			}
 			continue
 		}
+		if err, ok := attr.Value.Any().(error); ok && err != nil {
+			found = err
+		}
+	}
+	return found
+}
 
-		// Because the attributes slice we are iterating through is ordered from
-		// oldest to newest, we should overwrite the error/user to get the latest one.
-		// Because there could be multiple, we still add these to the MetaData map.
-		switch t := attr.Value.Any().(type) {
-		case error:
-			if t != nil {
-				*errForBugsnag = t
-			}
+// bugRecord contains what the logging goroutine captures for a bug: the
+// handler, context, record, call stack (unless the error has its own),
+// breadcrumbs, scope, and whether it is unhandled. Everything else is built
+// later by the NotifierWorkers, to keep the log call fast.
+type bugRecord struct {
+	h           *Handler
+	ctx         context.Context
+	record      slog.Record
+	stack       []uintptr
+	breadcrumbs []breadcrumb
+	scope       *scopeSnapshot
 
-		case bugsnag.User:
-			*user = t
+	// unhandled is decided at the log call, so that reconfiguring the
+	// UnhandledLevel doesn't change the bugs that are already queued
+	unhandled bool
 
-		case bugsnagUserID:
-			user.Id = t.BugsnagUserID()
+	// notifier will send the bug, and is set by the NotifierWorkers it is routed to
+	notifier *bugsnag.Notifier
 
-		case bugsnagUserName:
-			user.Name = t.BugsnagUserName()
+	// queued is when the bug was queued, if the NotifierWorkers are autoscaling
+	queued time.Time
 
-		case bugsnagUserEmail:
-			user.Email = t.BugsnagUserEmail()
-		}
+	// waiter, if not nil, is waiting for the bug to be sent
+	waiter *bugWaiter
+}
 
-		// Replace with filtered if the key matches
-		if shouldRedact(attr.Key, h.notifiers.notifier.Config.ParamsFilters) {
-			md.Add(tab, attr.Key, "[FILTERED]")
-			continue
-		}
+// bugReport contains everything needed to be sent off to bugsnag, preformatted
+type bugReport struct {
+	err     error
+	rawData []any
+}
 
-		// Always resolve log attribute values
-		attr.Value = attr.Value.Resolve()
-		md.Add(tab, attr.Key, attr.Value.Any())
+// logToBug creates and formats a bug, from a log record and the stack,
+// breadcrumbs, and scope captured at the log call. It collects the record's attributes
+// along with the handler's groups and attributes, and resolves all values.
+// The level of the error should be checked if sufficient or not before calling.
+func (h *Handler) logToBug(bug bugRecord) bugReport {
+	ctx, r := bug.ctx, bug.record
+	t, lvl, msg, pc := r.Time, r.Level, r.Message, r.PC
+	attrs := h.collectAttrs(r)
+	if len(h.ctxExtractors) > 0 && ctx != nil {
+		attrs = append(h.extractContextAttrs(ctx), attrs...)
 	}
-}
 
-func shouldRedact(key string, filters []string) bool {
-	for _, filter := range filters {
-		if strings.Contains(strings.ToLower(key), strings.ToLower(filter)) {
-			return true
-		}
+	// Find the errors and bugsnag.User's in the log attributes.
+	// Create MetaData for all the other information in the log.
+	b := h.newMetaDataBuilder()
+	if bug.notifier != nil {
+		b.filters = b.redaction.paramsFilters(bug.notifier)
+	}
+	var crumbsTab string
+	if len(bug.breadcrumbs) > 0 {
+		crumbsTab = b.claimTab(breadcrumbsTab)
 	}
-	return false
+	scopeAttrsTab, scopeTrailTab := b.claimScopeTabs(bug.scope)
+	b.accumulateRawData(nil, attrs)
+	b.addBreadcrumbs(crumbsTab, bug.breadcrumbs, true)
+	b.addScope(scopeAttrsTab, scopeTrailTab, bug.scope)
+
+	// Add in the log record info
+	frameStack := runtime.CallersFrames([]uintptr{pc})
+	frame, _ := frameStack.Next()
+	b.addBuiltIn(slog.Time(slog.TimeKey, t))
+	b.addBuiltIn(slog.Any(slog.LevelKey, lvl))
+	b.addBuiltIn(slog.String(slog.MessageKey, msg))
+	b.addBuiltIn(slog.Any(slog.SourceKey, &slog.Source{Function: frame.Function, File: frame.File, Line: frame.Line}))
+	b.finish()
+	errForBugsnag, md := b.err, b.md
+
+	// Log attributes override the context's user, field by field
+	user := mergeUser(h.userFromContext(ctx), b.user)
+
+	// Ensure the error is not nil and has a stack trace
+	errForBugsnag = newErrorWithStack(errForBugsnag, msg, pc, bug.stack)
+
+	// The order matters
+	rawData := []any{
+		ctx,
+		bugsnag.Context{String: h.bugContext(ctx, r, b.context)},
+		bugsnag.HandledState{Unhandled: bug.unhandled},
+		bsSeverity(lvl), // Must come after HandledState
+		md,
+	}
+	if user.Id != "" || user.Name != "" || user.Email != "" {
+		rawData = append(rawData, user)
+	}
+	for _, fn := range h.beforeNotify {
+		rawData = append(rawData, fn)
+	}
+	if b.redaction.enabled() {
+		rawData = append(rawData, b.redaction.redactEvent)
+	}
+	if b.limits != nil && b.limits.MaxEventSize > 0 {
+		// Measure the event once everything else has changed it
+		rawData = append(rawData, b.limitEventSize)
+	}
+	if !b.redaction.useParamsFilters {
+		// Stop bugsnag from applying its ParamsFilters to this bug when sending it
+		rawData = append(rawData, bugsnag.Configuration{ParamsFilters: []string{}})
+	}
+
+	return bugReport{err: errForBugsnag, rawData: rawData}
 }
 
 // bsSeverity converts a [slog.Level] to a [bugsnag.severity]
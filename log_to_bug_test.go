@@ -4,12 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"runtime"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -68,6 +70,15 @@ type bugsnagPayload struct {
 	// } `json:"notifier"`
 }
 
+type bugsnagSession struct {
+	// StartedAt time.Time `json:"startedAt"`
+	ID     string `json:"id"`
+	Events struct {
+		Handled   int `json:"handled"`
+		Unhandled int `json:"unhandled"`
+	} `json:"events"`
+}
+
 type bugsnagEvent struct {
 	// App struct {
 	// 	ReleaseStage string `json:"releaseStage"`
@@ -98,15 +109,8 @@ type bugsnagEvent struct {
 	} `json:"exceptions"`
 	MetaData map[string]map[string]any `json:"metaData"`
 	// PayloadVersion string                    `json:"payloadVersion"`
-	// Session        struct {
-	// 	StartedAt time.Time `json:"startedAt"`
-	// 	ID        string    `json:"id"`
-	// 	Events    struct {
-	// 		Handled   int `json:"handled"`
-	// 		Unhandled int `json:"unhandled"`
-	// 	} `json:"events"`
-	// } `json:"session"`
-	Severity string `json:"severity"`
+	Session  *bugsnagSession `json:"session"`
+	Severity string          `json:"severity"`
 	// SeverityReason struct {
 	// 	Type string `json:"type"`
 	// } `json:"severityReason"`
@@ -136,6 +140,9 @@ func TestLogToBug(t *testing.T) {
 				ErrorClass string `json:"errorClass"`
 				Message    string `json:"message"`
 			}{{
+				ErrorClass: "slogbugsnag.errorWithCallers",
+				Message:    "terrible error",
+			}, {
 				ErrorClass: "*errors.errorString",
 				Message:    "terrible error",
 			}},
@@ -185,6 +192,8 @@ func TestLogToBug(t *testing.T) {
 			},
 		}},
 	}
+	expectation.Events[0].Session = &bugsnagSession{}
+	expectation.Events[0].Session.Events.Unhandled = 1
 
 	// Create a real but temporary server
 	receivedCall := atomic.Bool{}
@@ -207,6 +216,9 @@ func TestLogToBug(t *testing.T) {
 			// Replace source field since it changes
 			t.Log(payload.Events[0].MetaData["log"]["source"])
 			expectation.Events[0].MetaData["log"]["source"] = payload.Events[0].MetaData["log"]["source"]
+			if payload.Events[0].Session != nil {
+				expectation.Events[0].Session.ID = payload.Events[0].Session.ID
+			}
 
 			if !reflect.DeepEqual(payload, expectation) {
 				t.Errorf("%#+v\n", payload)
@@ -227,8 +239,8 @@ func TestLogToBug(t *testing.T) {
 
 	// Temporary handler
 	h := Handler{
-		unhandledLevel: slog.LevelError,
-		notifiers:      &NotifierWorkers{notifier: notifier},
+		config:    newLiveConfig(Config{UnhandledLevel: slog.LevelError}),
+		notifiers: &NotifierWorkers{notifier: notifier},
 	}
 
 	// Set up the log contents
@@ -278,8 +290,11 @@ func TestLogToBug(t *testing.T) {
 		),
 	}
 
+	r := slog.NewRecord(defaultTime, slog.LevelError, "main message", pc)
+	r.AddAttrs(attrs...)
+
 	// Call log to bug
-	bug := h.logToBug(ctx, defaultTime, slog.LevelError, "main message", pc, attrs)
+	bug := h.logToBug(bugRecord{h: &h, ctx: ctx, record: r, stack: callers(), unhandled: true})
 
 	// Send the bug to our fake bugsnag server to verify the content
 	err = h.notifiers.notifier.NotifySync(bug.err, true, bug.rawData...)
@@ -291,3 +306,177 @@ func TestLogToBug(t *testing.T) {
 		t.Error("Test server did not receive call")
 	}
 }
+
+func TestUserFromContext(t *testing.T) {
+	t.Parallel()
+
+	svr := newBugsnagTestServer(t)
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 1})
+
+	type principal struct{ id, email string }
+	type principalKey struct{}
+	logger := slog.New(NewHandler(&testHandler{}, &HandlerOptions{
+		Notifiers: notifiers,
+		UserFromContext: func(ctx context.Context) (bugsnag.User, bool) {
+			p, ok := ctx.Value(principalKey{}).(principal)
+			return bugsnag.User{Id: p.id, Email: p.email}, ok
+		},
+	}))
+
+	ctx := ContextWithUser(context.Background(), bugsnag.User{Id: "ctx-id", Name: "ctx-name", Email: "ctx-email"})
+	logger.ErrorContext(ctx, "helper only")
+
+	ctx = context.WithValue(ctx, principalKey{}, principal{id: "auth-id", email: "auth-email"})
+	logger.ErrorContext(ctx, "with principal")
+	logger.ErrorContext(ctx, "with attributes", "user", bugsnag.User{Email: "attr-email"}, "name", Name("attr-name"))
+
+	notifiers.Close()
+
+	expected := map[string]string{
+		"helper only":     "ctx-id ctx-name ctx-email",
+		"with principal":  "auth-id ctx-name auth-email",
+		"with attributes": "auth-id attr-name attr-email",
+	}
+	events := svr.Events()
+	if len(events) != len(expected) {
+		t.Fatal("Expected 3 bugsnag events; Got:", len(events))
+	}
+	for _, event := range events {
+		if got := event.User.ID + " " + event.User.Name + " " + event.User.Email; got != expected[event.Context] {
+			t.Errorf("%s: Expected user %q; Got: %q", event.Context, expected[event.Context], got)
+		}
+	}
+}
+
+func TestContextExtractors(t *testing.T) {
+	t.Parallel()
+
+	svr := newBugsnagTestServer(t)
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 1})
+
+	type attrsKey struct{}
+	tester := &testHandler{}
+	logger := slog.New(NewHandler(tester, &HandlerOptions{
+		Notifiers: notifiers,
+		ContextExtractors: []ContextExtractor{
+			func(ctx context.Context) []slog.Attr {
+				attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+				return attrs
+			},
+			func(ctx context.Context) []slog.Attr {
+				return []slog.Attr{slog.Group("request", slog.String("id", "abc"))}
+			},
+		},
+	})).WithGroup("g")
+
+	ctx := context.WithValue(context.Background(), attrsKey{}, []slog.Attr{
+		slog.String("from_ctx", "foo"),
+		slog.String("overridden", "ctx"),
+		slog.Any("err", errors.New("ctx error")),
+	})
+	logger.ErrorContext(ctx, "main message", "overridden", "log", "err", errors.New("log error"))
+
+	notifiers.Close()
+
+	// The extracted attributes are not passed to the next handler
+	if strings.Contains(tester.String(), "from_ctx") {
+		t.Error("Expected the context attributes to only go to bugsnag; Got:", tester.String())
+	}
+
+	events := svr.Events()
+	if len(events) != 1 {
+		t.Fatal("Expected 1 bugsnag event; Got:", len(events))
+	}
+	md := events[0].MetaData
+	if md["log"]["from_ctx"] != "foo" || md["log"]["overridden"] != "ctx" || md["g"]["overridden"] != "log" || md["request"]["id"] != "abc" {
+		t.Errorf("%#+v\n", md)
+	}
+	if len(events[0].Exceptions) == 0 || events[0].Exceptions[0].Message != "log error" {
+		t.Errorf("Expected the log's error to override the context's; Got: %#+v\n", events[0].Exceptions)
+	}
+}
+
+func TestNotifyHooks(t *testing.T) {
+	t.Parallel()
+
+	svr := newBugsnagTestServer(t)
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 1})
+
+	type ctxKey struct{}
+	var notified []string
+	logger := slog.New(NewHandler(&testHandler{}, &HandlerOptions{
+		Notifiers: notifiers,
+		OnNotify: []func(ctx context.Context, r slog.Record, err error){
+			func(ctx context.Context, r slog.Record, err error) {
+				notified = append(notified, fmt.Sprintf("%s %v %v", r.Message, err, ctx.Value(ctxKey{})))
+			},
+		},
+		BeforeNotify: []func(event *bugsnag.Event){
+			func(event *bugsnag.Event) {
+				event.Context = fmt.Sprint(event.Ctx.Value(ctxKey{}))
+			},
+		},
+	})).With("err", errors.New("with error"))
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "ctx value")
+	logger.InfoContext(ctx, "not notified")
+	logger.ErrorContext(ctx, "with attrs")
+	logger.ErrorContext(ctx, "grouped", slog.Group("g", slog.Any("err", errors.New("grouped error"))))
+
+	notifiers.Close()
+
+	expected := []string{"with attrs with error ctx value", "grouped grouped error ctx value"}
+	if !reflect.DeepEqual(notified, expected) {
+		t.Errorf("Expected: %#+v\nGot: %#+v\n", expected, notified)
+	}
+
+	events := svr.Events()
+	if len(events) != 2 || events[0].Context != "ctx value" || events[1].Context != "ctx value" {
+		t.Errorf("%#+v\n", events)
+	}
+}
+
+func TestBugsnagContext(t *testing.T) {
+	t.Parallel()
+
+	svr := newBugsnagTestServer(t)
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 1})
+	logger := slog.New(NewHandler(&testHandler{}, &HandlerOptions{
+		Notifiers: notifiers,
+		BugsnagContext: func(ctx context.Context, r slog.Record) string {
+			var job string
+			r.Attrs(func(a slog.Attr) bool {
+				if a.Key == "job" {
+					job = a.Value.String()
+				}
+				return true
+			})
+			return job
+		},
+	}))
+
+	ctx := ContextWithBugsnagContext(context.Background(), "GET /users/{id}")
+	logger.Error("message only")
+	logger.Error("from callback", "job", "cleanup")
+	logger.ErrorContext(ctx, "from ctx", "job", "cleanup")
+	logger.ErrorContext(ctx, "from attr", "job", "cleanup", "ctx", Context("attr context"))
+
+	notifiers.Close()
+
+	expected := map[string]string{
+		"message only":  "message only",
+		"from callback": "cleanup",
+		"from ctx":      "GET /users/{id}",
+		"from attr":     "attr context",
+	}
+	events := svr.Events()
+	if len(events) != len(expected) {
+		t.Fatal("Expected 4 bugsnag events; Got:", len(events))
+	}
+	for _, event := range events {
+		msg, _ := event.MetaData["log"]["msg"].(string)
+		if event.Context != expected[msg] {
+			t.Errorf("%s: Expected context %q; Got: %q", msg, expected[msg], event.Context)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package slogbugsnag
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+)
+
+// logTab is the bugsnag metadata tab that all root-level attributes,
+// and the log record's time, level, message, and source go in.
+const logTab = "log"
+
+// TabNamer decides which bugsnag metadata tab the attributes inside a group
+// are put in. It receives the path of group names, from the outermost to the
+// innermost, and returns the name of the tab, and the path of nested maps
+// within the tab that the attributes should be put in (which may be empty).
+// It is never called for root-level attributes, which go in the "log" tab.
+//
+// Different group paths that end up with the same tab name will collide.
+// Collisions are resolved deterministically: the first group path to claim a
+// tab name keeps it, later ones are renamed to their full dotted group path,
+// and if that is also taken, suffixed with a number (ex: "a.b (2)").
+type TabNamer func(groups []string) (tab string, nested []string)
+
+var (
+	_ TabNamer = InnermostGroupTab // Validate implements type
+	_ TabNamer = TopLevelGroupTab  // Validate implements type
+	_ TabNamer = GroupPathTab      // Validate implements type
+)
+
+// InnermostGroupTab names the tab after the innermost group.
+// This is the default.
+//
+//	a.b.c.key=value -> tab "c": {key: value}
+func InnermostGroupTab(groups []string) (string, []string) {
+	return groups[len(groups)-1], nil
+}
+
+// TopLevelGroupTab names the tab after the outermost group, with any inner
+// groups as nested maps inside the tab.
+//
+//	a.b.c.key=value -> tab "a": {b: {c: {key: value}}}
+func TopLevelGroupTab(groups []string) (string, []string) {
+	return groups[0], groups[1:]
+}
+
+// GroupPathTab names the tab after the full dotted path of groups.
+//
+//	a.b.c.key=value -> tab "a.b.c": {key: value}
+func GroupPathTab(groups []string) (string, []string) {
+	return strings.Join(groups, "."), nil
+}
+
+// metaDataBuilder accumulates log attributes into [bugsnag.MetaData] tabs,
+// and finds the latest [error], [bugsnag.User], and [Context] among them.
+type metaDataBuilder struct {
+	h       *Handler
+	md      bugsnag.MetaData
+	err     error
+	user    bugsnag.User
+	context string
+
+	redaction *redaction
+	filters   []string // key redaction filters, in addition to the redaction's own
+	limits    *limits
+	truncated truncations
+	encoder   ValueEncoder
+	tabNamer  TabNamer
+	tabs      map[string]string // group path -> resolved tab name
+	claimed   map[string]string // tab name -> group path that claimed it
+	nested    map[string]bool   // resolved paths of the nested group maps inside tabs
+}
+
+// newMetaDataBuilder returns a metaDataBuilder, with the log tab already claimed
+func (h *Handler) newMetaDataBuilder() *metaDataBuilder {
+	tabNamer := h.tabNamer
+	if tabNamer == nil {
+		tabNamer = InnermostGroupTab
+	}
+	redaction := h.current().redaction
+	return &metaDataBuilder{
+		h:         h,
+		md:        bugsnag.MetaData{},
+		redaction: redaction,
+		filters:   redaction.paramsFilters(h.notifiers.notifier),
+		limits:    h.limits,
+		encoder:   h.valueEncoder,
+		tabNamer:  tabNamer,
+		tabs:      map[string]string{"": logTab},
+		claimed:   map[string]string{logTab: ""},
+		nested:    map[string]bool{},
+	}
+}
+
+// accumulateRawData recursively iterates through all attributes and turns them
+// into [bugsnag.MetaData] tabs. The log tab is used for all root-level attributes.
+// All attributes in groups get put in tabs named by the TabNamer.
+// Attribute values are resolved, then passed through ReplaceAttr if set,
+// then redacted based on the redaction keys (merged with the notifier config
+// ParamsFilters) and the value redactors.
+// Keys are redacted at every level: groups, map keys, and struct fields.
+// accumulateRawData also finds the latest [error], [bugsnag.User], and [Context].
+func (b *metaDataBuilder) accumulateRawData(groups []string, attrs []slog.Attr) {
+	for _, attr := range attrs {
+		// Because the attributes slice we are iterating through is ordered from
+		// oldest to newest, we should overwrite the error/user to get the latest one.
+		// Because there could be multiple, we still add these to the MetaData map.
+		switch t := attr.Value.Any().(type) {
+		case error:
+			if t != nil {
+				b.err = t
+			}
+
+		case bugsnag.User:
+			b.user = t
+
+		case bugsnagUserID:
+			b.user.Id = t.BugsnagUserID()
+
+		case bugsnagUserName:
+			b.user.Name = t.BugsnagUserName()
+
+		case bugsnagUserEmail:
+			b.user.Email = t.BugsnagUserEmail()
+
+		case bugsnagContext:
+			b.context = t.BugsnagContext()
+		}
+
+		// Always resolve log attribute values
+		attr.Value = attr.Value.Resolve()
+
+		// Let the user rewrite or drop the attribute
+		if b.h.replaceAttr != nil && attr.Value.Kind() != slog.KindGroup {
+			attr = b.h.replaceAttr(groups, attr)
+			attr.Value = attr.Value.Resolve()
+			if attr.Equal(slog.Attr{}) {
+				continue
+			}
+		}
+
+		// Replace with filtered if the key matches, including for whole groups
+		if b.redaction.redactKey(attr.Key, b.filters) {
+			b.add(groups, attr.Key, b.redaction.replacement)
+			continue
+		}
+
+		if attr.Value.Kind() == slog.KindGroup {
+			b.accumulateGroup(groups, attr)
+			continue
+		}
+
+		b.add(groups, attr.Key, b.sanitizeAttrValue(attr.Value))
+	}
+}
+
+// accumulateGroup accumulates the attributes inside a group attribute
+func (b *metaDataBuilder) accumulateGroup(groups []string, attr slog.Attr) {
+	// Groups with empty keys are inlined
+	if attr.Key == "" {
+		b.accumulateRawData(groups, attr.Value.Group())
+		return
+	}
+	b.accumulateRawData(append(groups[:len(groups):len(groups)], attr.Key), attr.Value.Group())
+}
+
+// addBuiltIn adds one of the log record's built-in fields (time, level, msg,
+// source) to the log tab, after passing it through ReplaceAttr if set.
+func (b *metaDataBuilder) addBuiltIn(attr slog.Attr) {
+	if b.h.replaceAttr != nil {
+		attr = b.h.replaceAttr(nil, attr)
+		attr.Value = attr.Value.Resolve()
+		if attr.Equal(slog.Attr{}) {
+			return
+		}
+	}
+
+	var value any
+	switch t := attr.Value.Any().(type) {
+	case time.Time:
+		value = t.Format(time.RFC3339Nano)
+	case slog.Level:
+		value = t.String()
+	case *slog.Source:
+		value = fmt.Sprintf("%s:%d", t.Function, t.Line)
+	default:
+		value = b.sanitizeAttrValue(attr.Value)
+	}
+	b.md.Add(logTab, attr.Key, value)
+}
+
+// add puts the key and value in the tab for the group path
+func (b *metaDataBuilder) add(groups []string, key string, value any) {
+	tab, nested := b.tab(groups)
+
+	m := b.md[tab]
+	if m == nil {
+		m = map[string]any{}
+		b.md[tab] = m
+	}
+
+	// Drop new attributes once the tab is full
+	if _, ok := m[nestedRoot(nested, key)]; !ok && b.limits.tooManyAttrs(len(m)) {
+		b.truncated.attrs++
+		return
+	}
+
+	// A value and a nested group with the same key are both kept, by giving
+	// the later one a numbered suffix, the same way tab name collisions are resolved
+	path := tab
+	for _, k := range nested {
+		k = b.freeKey(m, path, k, true)
+		path += "\x00" + k
+		sub, ok := m[k].(map[string]any)
+		if !ok {
+			sub = map[string]any{}
+			m[k] = sub
+			b.nested[path] = true
+		}
+		m = sub
+	}
+	m[b.freeKey(m, path, key, false)] = value
+}
+
+// freeKey returns the key, or the key with a numbered suffix (ex: "key (2)"),
+// that can hold a nested group (or a value, if not group) inside the map at the path.
+// A value can replace an earlier value with the same key, but not a nested group,
+// and a nested group can only be shared with the same nested group.
+func (b *metaDataBuilder) freeKey(m map[string]any, path, key string, group bool) string {
+	k := key
+	for i := 2; ; i++ {
+		if _, ok := m[k]; !ok || b.nested[path+"\x00"+k] == group {
+			return k
+		}
+		k = key + " (" + strconv.Itoa(i) + ")"
+	}
+}
+
+// nestedRoot returns the key in the tab that the value will be put under
+func nestedRoot(nested []string, key string) string {
+	if len(nested) > 0 {
+		return nested[0]
+	}
+	return key
+}
+
+// finish notes any truncation in the log tab.
+// It must be called after all attributes have been added.
+func (b *metaDataBuilder) finish() {
+	b.noteTruncation(b.md)
+}
+
+// tab returns the tab name and nested map path for the group path,
+// resolving any collisions with tabs already claimed by other group paths.
+func (b *metaDataBuilder) tab(groups []string) (string, []string) {
+	if len(groups) == 0 {
+		return logTab, nil
+	}
+
+	name, nested := b.tabNamer(groups)
+
+	// The group path that owns the tab excludes the nested maps inside it
+	owner := groups
+	if len(nested) <= len(groups) {
+		owner = groups[:len(groups)-len(nested)]
+	}
+	ownerKey := strings.Join(owner, "\x00")
+	if tab, ok := b.tabs[ownerKey]; ok {
+		return tab, nested
+	}
+
+	tab := name
+	if claimedBy, ok := b.claimed[tab]; ok && claimedBy != ownerKey {
+		tab = strings.Join(owner, ".")
+		for i := 2; ; i++ {
+			if claimedBy, ok = b.claimed[tab]; !ok || claimedBy == ownerKey {
+				break
+			}
+			tab = strings.Join(owner, ".") + " (" + strconv.Itoa(i) + ")"
+		}
+	}
+
+	b.tabs[ownerKey] = tab
+	b.claimed[tab] = ownerKey
+	return tab, nested
+}
+
+// claimTab claims the tab for the handler's own use, such as breadcrumbs.
+// It must be called before any attributes are added, so that any group with
+// the same tab name is renamed instead.
+func (b *metaDataBuilder) claimTab(name string) string {
+	b.claimed[name] = "\x01" + name
+	return name
+}
@@ -0,0 +1,213 @@
+package slogbugsnag
+
+import (
+	"errors"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+)
+
+func TestTabNamer(t *testing.T) {
+	t.Parallel()
+
+	attrs := []slog.Attr{
+		slog.String("root", "r"),
+		slog.Group("a",
+			slog.String("ka", "va"),
+			slog.Group("b",
+				slog.Group("c", slog.String("kabc", "vabc")),
+			),
+		),
+		slog.Group("x",
+			slog.Group("c", slog.String("kxc", "vxc")),
+			slog.Group("", slog.String("inlined", "vx")),
+		),
+		slog.Group("log", slog.String("klog", "vlog")),
+	}
+
+	tests := []struct {
+		name     string
+		tabNamer TabNamer
+		expected bugsnag.MetaData
+	}{
+		{
+			name:     "InnermostGroupTab",
+			tabNamer: InnermostGroupTab,
+			expected: bugsnag.MetaData{
+				"log":     {"root": "r"},
+				"a":       {"ka": "va"},
+				"c":       {"kabc": "vabc"},
+				"x.c":     {"kxc": "vxc"},
+				"x":       {"inlined": "vx"},
+				"log (2)": {"klog": "vlog"},
+			},
+		},
+		{
+			name:     "TopLevelGroupTab",
+			tabNamer: TopLevelGroupTab,
+			expected: bugsnag.MetaData{
+				"log": {"root": "r"},
+				"a": {
+					"ka": "va",
+					"b":  map[string]any{"c": map[string]any{"kabc": "vabc"}},
+				},
+				"x": {
+					"c":       map[string]any{"kxc": "vxc"},
+					"inlined": "vx",
+				},
+				"log (2)": {"klog": "vlog"},
+			},
+		},
+		{
+			name:     "GroupPathTab",
+			tabNamer: GroupPathTab,
+			expected: bugsnag.MetaData{
+				"log":     {"root": "r"},
+				"a":       {"ka": "va"},
+				"a.b.c":   {"kabc": "vabc"},
+				"x.c":     {"kxc": "vxc"},
+				"x":       {"inlined": "vx"},
+				"log (2)": {"klog": "vlog"},
+			},
+		},
+		{
+			name: "Custom",
+			tabNamer: func(groups []string) (string, []string) {
+				return "all", nil
+			},
+			expected: bugsnag.MetaData{
+				"log":     {"root": "r"},
+				"all":     {"ka": "va"},
+				"a.b.c":   {"kabc": "vabc"},
+				"x.c":     {"kxc": "vxc"},
+				"x":       {"inlined": "vx"},
+				"log (2)": {"klog": "vlog"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				notifiers: &NotifierWorkers{notifier: bugsnag.New()},
+				tabNamer:  tc.tabNamer,
+			}
+			b := h.newMetaDataBuilder()
+			b.accumulateRawData(nil, attrs)
+
+			if !reflect.DeepEqual(b.md, tc.expected) {
+				t.Errorf("%#+v\n", b.md)
+			}
+		})
+	}
+}
+
+func TestNestedGroupCollisions(t *testing.T) {
+	t.Parallel()
+
+	h := &Handler{
+		notifiers: &NotifierWorkers{notifier: bugsnag.New()},
+		tabNamer:  TopLevelGroupTab,
+	}
+	b := h.newMetaDataBuilder()
+	b.accumulateRawData(nil, []slog.Attr{
+		slog.Group("a",
+			slog.String("b", "value first"),
+			slog.Group("b", slog.Int("k", 1)),
+			slog.Group("c", slog.Int("k", 2)),
+			slog.String("c", "value second"),
+			slog.Group("c", slog.Int("k2", 3)),
+			slog.String("c", "value replaced"),
+			slog.Any("d", map[string]any{"not": "a group"}),
+			slog.Group("d", slog.Int("k", 4)),
+		),
+	})
+
+	expected := bugsnag.MetaData{
+		"a": {
+			"b":     "value first",
+			"b (2)": map[string]any{"k": int64(1)},
+			"c":     map[string]any{"k": int64(2), "k2": int64(3)},
+			"c (2)": "value replaced",
+			"d":     map[string]any{"not": "a group"},
+			"d (2)": map[string]any{"k": int64(4)},
+		},
+	}
+	if !reflect.DeepEqual(b.md, expected) {
+		t.Errorf("%#+v\n", b.md)
+	}
+}
+
+func TestReplaceAttr(t *testing.T) {
+	t.Parallel()
+
+	var calledGroups [][]string
+	h := &Handler{
+		notifiers: &NotifierWorkers{notifier: bugsnag.New()},
+		replaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			calledGroups = append(calledGroups, groups)
+			switch a.Key {
+			case "err", slog.SourceKey:
+				return slog.Attr{} // Drop
+			case "rename":
+				a.Key = "renamed"
+			case "expand":
+				return slog.Group("expanded", slog.String("inner", a.Value.String()))
+			case slog.LevelKey:
+				a.Value = slog.StringValue("LVL-" + a.Value.Any().(slog.Level).String())
+			case slog.MessageKey:
+				a.Key = "message"
+			case "blank":
+				a.Key = "" // Kept, like slog does
+			case "inline":
+				return slog.Group("", slog.String("inlined", a.Value.String()))
+			}
+			return a
+		},
+	}
+
+	err := errors.New("terrible error")
+
+	b := h.newMetaDataBuilder()
+	b.accumulateRawData(nil, []slog.Attr{
+		slog.Any("err", err),
+		slog.String("rename", "foo"),
+		slog.Group("g", slog.String("expand", "bar")),
+		slog.String("blank", "kept"),
+		slog.String("inline", "baz"),
+	})
+	b.addBuiltIn(slog.Time(slog.TimeKey, defaultTime))
+	b.addBuiltIn(slog.Any(slog.LevelKey, slog.LevelError))
+	b.addBuiltIn(slog.String(slog.MessageKey, "main message"))
+	b.addBuiltIn(slog.Any(slog.SourceKey, &slog.Source{Function: "main.main", Line: 10}))
+
+	expected := bugsnag.MetaData{
+		"log": {
+			"renamed": "foo",
+			"":        "kept",
+			"inlined": "baz",
+			"time":    "2023-09-29T13:00:59Z",
+			"level":   "LVL-ERROR",
+			"message": "main message",
+		},
+		"expanded": {"inner": "bar"},
+	}
+	if !reflect.DeepEqual(b.md, expected) {
+		t.Errorf("%#+v\n", b.md)
+	}
+
+	// The error is still found, even though it was dropped from the metadata
+	if b.err != err {
+		t.Error("Expected error to be found; Got:", b.err)
+	}
+
+	expectedGroups := [][]string{nil, nil, {"g"}, {"g", "expanded"}, nil, nil, nil, nil, nil, nil, nil}
+	if !reflect.DeepEqual(calledGroups, expectedGroups) {
+		t.Errorf("%#+v\n", calledGroups)
+	}
+}
@@ -0,0 +1,148 @@
+package slogbugsnag
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"sync"
+	"time"
+)
+
+var _ withCallers = &PanicError{} // Validate implements interface
+
+// PanicError is a recovered panic, along with the stack trace of the
+// goroutine that panicked, starting where the panic happened.
+type PanicError struct {
+	// Value is the value the goroutine panicked with
+	Value any
+
+	stack []uintptr
+}
+
+// newPanicError returns a PanicError for the recovered value.
+// It must be called by the deferred function that recovered the panic,
+// so that the stack of the panicking goroutine can still be captured.
+func newPanicError(value any) *PanicError {
+	return &PanicError{Value: value, stack: panicStack(callers())}
+}
+
+// Error returns the panic value as a string
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Callers returns the raw stack frames as returned by runtime.Callers(),
+// starting where the panic happened
+func (e *PanicError) Callers() []uintptr {
+	return e.stack
+}
+
+// Unwrap returns the panic value if it is an error, for Go 1.13 error chains
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// panicStack trims the frames of the recovering functions, and of the panic
+// itself, off the stack, so that it starts where the panic happened.
+// If the stack does not contain a panic, it is returned whole.
+func panicStack(stack []uintptr) []uintptr {
+	for i, pc := range stack {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		if frame.Function == "runtime.gopanic" {
+			return stack[i+1:]
+		}
+	}
+	return stack
+}
+
+// Recover recovers a panic, and logs it as an error with the panic's stack
+// trace, at the handler's UnhandledLevel, with the logger's attributes and the
+// context. It then blocks until the bug has been sent to bugsnag, but not
+// for any other bugs that are queued.
+// It must be deferred directly, so that it can recover the panic:
+//
+//	defer slogbugsnag.Recover(ctx, logger)
+//
+// The level is the UnhandledLevel of the logger's handler, if it is a Handler.
+// Otherwise, the default UnhandledLevel (slog.LevelError + 4) is used.
+// If logger is nil, slog.Default() is used.
+func Recover(ctx context.Context, logger *slog.Logger) {
+	if rec := recover(); rec != nil {
+		logPanic(ctx, logger, nil, newPanicError(rec))
+	}
+}
+
+// RecoverAndRepanic is the same as [Recover], except that it panics again
+// with the same value, after the bug has been sent to bugsnag.
+// It must be deferred directly, so that it can recover the panic:
+//
+//	defer slogbugsnag.RecoverAndRepanic(ctx, logger)
+func RecoverAndRepanic(ctx context.Context, logger *slog.Logger) {
+	if rec := recover(); rec != nil {
+		logPanic(ctx, logger, nil, newPanicError(rec))
+		panic(rec)
+	}
+}
+
+// Go runs the function in a new goroutine, recovering and logging any panic
+// with [Recover], using slog.Default() as the logger.
+func Go(ctx context.Context, fn func(ctx context.Context)) {
+	go func() {
+		defer Recover(ctx, nil)
+		fn(ctx)
+	}()
+}
+
+// logPanic logs the panic, as if it was logged from where the panic happened.
+// If level is nil, the handler's UnhandledLevel is used.
+// It waits for the bug to be sent by every Handler that the record reaches,
+// but not for any other bugs.
+func logPanic(ctx context.Context, logger *slog.Logger, level slog.Leveler, perr *PanicError) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	h, _ := logger.Handler().(*Handler)
+	if level == nil {
+		level = slog.LevelError + 4
+		if h != nil {
+			level = h.current().unhandledLevel
+		}
+	}
+	if !logger.Enabled(ctx, level.Level()) {
+		return
+	}
+
+	var pc uintptr
+	if len(perr.stack) > 0 {
+		pc = perr.stack[0]
+	}
+	r := slog.NewRecord(time.Now(), level.Level(), perr.Error(), pc)
+	r.AddAttrs(slog.Any("err", perr))
+
+	w := &bugWaiter{}
+	_ = logger.Handler().Handle(context.WithValue(ctx, bugWaiterKey{}, w), r)
+	w.wg.Wait()
+}
+
+// bugWaiterKey is the context key for a bugWaiter
+type bugWaiterKey struct{}
+
+// bugWaiter waits for the bugs of the records logged with it in their
+// context to be sent, or dropped
+type bugWaiter struct {
+	wg sync.WaitGroup
+}
+
+// waiterFromContext returns the bugWaiter in the context, if there is one
+func waiterFromContext(ctx context.Context) *bugWaiter {
+	if ctx == nil {
+		return nil
+	}
+	w, _ := ctx.Value(bugWaiterKey{}).(*bugWaiter)
+	return w
+}
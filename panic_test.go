@@ -0,0 +1,134 @@
+package slogbugsnag
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPanicError(t *testing.T) {
+	t.Parallel()
+
+	errPanic := errors.New("oh no")
+	var perr *PanicError
+	func() {
+		defer func() {
+			perr = newPanicError(recover())
+		}()
+		panic(errPanic)
+	}()
+
+	if perr.Error() != "panic: oh no" || !errors.Is(perr, errPanic) {
+		t.Errorf("%#+v\n", perr)
+	}
+
+	// The stack should start where the panic happened
+	frame, _ := runtime.CallersFrames(perr.Callers()).Next()
+	if !strings.HasSuffix(frame.Function, "TestPanicError.func1") {
+		t.Error("Expected the stack to start at the panic; Got:", frame.Function)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	t.Parallel()
+
+	svr := newBugsnagTestServer(t)
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 1})
+	defer notifiers.Close()
+	logger := slog.New(NewHandler(&testHandler{}, &HandlerOptions{Notifiers: notifiers})).With("with", "arg")
+
+	func() {
+		defer Recover(context.Background(), logger)
+		panic("recovered")
+	}()
+
+	func() {
+		defer func() {
+			if rec := recover(); rec != "repanicked" {
+				t.Error("Expected to panic again; Got:", rec)
+			}
+		}()
+		defer RecoverAndRepanic(context.Background(), logger)
+		panic("repanicked")
+	}()
+
+	// The queue was flushed, without closing the workers
+	events := svr.Events()
+	if len(events) != 2 {
+		t.Fatal("Expected 2 bugsnag events; Got:", len(events))
+	}
+	for i, expected := range []string{"panic: recovered", "panic: repanicked"} {
+		event := events[i]
+		if event.Context != expected || !event.Unhandled || event.MetaData["log"]["with"] != "arg" ||
+			!strings.HasPrefix(event.MetaData["log"]["source"].(string), "github.com/veqryn/slog-bugsnag.TestRecover.func") {
+			t.Errorf("%#+v\n", event)
+		}
+	}
+}
+
+func TestGo(t *testing.T) {
+	// Not parallel, because it sets the default logger
+	svr := newBugsnagTestServer(t)
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 1})
+	defer notifiers.Close()
+
+	defaultLogger := slog.Default()
+	defer slog.SetDefault(defaultLogger)
+	slog.SetDefault(slog.New(NewHandler(&testHandler{}, &HandlerOptions{Notifiers: notifiers})))
+
+	done := make(chan struct{})
+	Go(context.Background(), func(ctx context.Context) {
+		defer close(done)
+		panic("in a goroutine")
+	})
+	<-done
+
+	// Recover runs after the function's own deferred calls
+	deadline := time.Now().Add(5 * time.Second)
+	for len(svr.Events()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	events := svr.Events()
+	if len(events) != 1 || events[0].Context != "panic: in a goroutine" || !events[0].Unhandled {
+		t.Errorf("%#+v\n", events)
+	}
+}
+
+func TestRecoverWaitsForItsOwnBug(t *testing.T) {
+	t.Parallel()
+
+	// Another bug is stuck being sent, in another pool
+	gate := make(chan struct{})
+	slow := NewNotifierWorkers(&NotifierOptions{Notifier: newGatedBugsnagTestServer(t, gate).Notifier()})
+	svr := newBugsnagTestServer(t)
+	h := NewHandler(&testHandler{}, &HandlerOptions{
+		Notifiers:  NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier()}),
+		RouteRules: []RouteRule{{AttrKey: "slow", Notifiers: slow}},
+	})
+	logger := slog.New(h)
+	logger.Error("stuck", "slow", true)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer Recover(context.Background(), logger)
+		panic("oh no")
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Recover to only wait for its own bug")
+	}
+	if events := svr.Events(); len(events) != 1 || events[0].Context != "panic: oh no" {
+		t.Errorf("Expected the panic to be sent before Recover returned; Got: %#+v\n", events)
+	}
+
+	close(gate)
+	h.Close()
+}
@@ -0,0 +1,346 @@
+package slogbugsnag
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+)
+
+// filtered is what redacted values are replaced with
+const filtered = "[FILTERED]"
+
+// RedactOptions are options for redacting secrets and personally identifiable
+// information out of bugs before they are sent to bugsnag.
+// Each handler has its own redaction policy, even when several handlers share
+// the same NotifierWorkers.
+type RedactOptions struct {
+	// Keys are filters for the keys whose values should be redacted. They are
+	// merged with the ParamsFilters of the NotifierWorkers' bugsnag notifier,
+	// unless IgnoreParamsFilters is true.
+	Keys []string
+
+	// IgnoreParamsFilters, if true, stops the handler's bugs from being
+	// redacted by the notifier config ParamsFilters, so that only Keys are used.
+	// This applies to the handler's own redaction, and to bugsnag's, which
+	// otherwise redacts the keys again when it sends each bug.
+	// Other handlers sharing the notifier are not affected.
+	IgnoreParamsFilters bool
+
+	// Replacement is what redacted values, and the redacted parts of strings,
+	// are replaced with. Defaults to "[FILTERED]".
+	Replacement string
+
+	// Values are applied to every string sent to bugsnag: the metadata values
+	// (recursing into maps, slices, arrays, and structs), the log message,
+	// the bugsnag context, and the error messages.
+	// See [CommonRedactors] for a set of redactors for common secrets.
+	Values []Redactor
+
+	// KeyMatch decides how the key filters are matched against keys.
+	// Keys are checked at every level: attributes, groups, map keys,
+	// [slog.LogValuer] results, and struct fields (using their json names).
+	// Defaults to KeyMatchSubstring.
+	KeyMatch KeyMatch
+}
+
+// KeyMatch decides how key filters are matched against keys.
+// All matching is case-insensitive.
+type KeyMatch int
+
+const (
+	// KeyMatchSubstring redacts keys that contain any filter, the same way
+	// bugsnag matches its ParamsFilters. For example, "password" redacts
+	// "password", "old_password", and "PasswordHash".
+	KeyMatchSubstring KeyMatch = iota
+
+	// KeyMatchGlob redacts keys that entirely match any filter, which may
+	// contain the wildcards '*' (any run of characters) and '?' (any single
+	// character). For example, "password" redacts only "password", and
+	// "*_token" redacts "access_token" and "refresh_token" but not "tokens".
+	KeyMatchGlob
+)
+
+// Redactor redacts secrets and personally identifiable information out of
+// strings before they are sent to bugsnag.
+type Redactor interface {
+	// Redact returns s with any sensitive information in it replaced by the replacement.
+	Redact(s, replacement string) string
+}
+
+var _ Redactor = RedactorFunc(nil) // Validate implements interface
+
+// RedactorFunc is an adapter to allow the use of ordinary functions as Redactors.
+type RedactorFunc func(s, replacement string) string
+
+// Redact calls f(s, replacement)
+func (f RedactorFunc) Redact(s, replacement string) string {
+	return f(s, replacement)
+}
+
+var _ Redactor = &RegexpRedactor{} // Validate implements interface
+
+// RegexpRedactor redacts all matches of a regular expression.
+// If the expression has a capturing group, and it participates in the match,
+// only the text matched by the first capturing group is replaced.
+// Otherwise, the whole match is replaced.
+type RegexpRedactor struct {
+	Regexp *regexp.Regexp
+}
+
+// NewRegexpRedactor compiles the regular expression and returns a RegexpRedactor.
+// It panics if the expression cannot be parsed.
+func NewRegexpRedactor(expr string) *RegexpRedactor {
+	return &RegexpRedactor{Regexp: regexp.MustCompile(expr)}
+}
+
+// Redact returns s with all matches replaced by the replacement
+func (r *RegexpRedactor) Redact(s, replacement string) string {
+	matches := r.Regexp.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+
+	var sb strings.Builder
+	var last int
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) >= 4 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		sb.WriteString(s[last:start])
+		sb.WriteString(replacement)
+		last = end
+	}
+	sb.WriteString(s[last:])
+	return sb.String()
+}
+
+var (
+	// RedactJWTs redacts JSON Web Tokens
+	RedactJWTs Redactor = NewRegexpRedactor(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+	// RedactBearerTokens redacts the token of "Bearer" authorization credentials
+	RedactBearerTokens Redactor = NewRegexpRedactor(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`)
+
+	// RedactEmails redacts email addresses
+	RedactEmails Redactor = NewRegexpRedactor(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+	// RedactAWSKeys redacts AWS access key ID's, and secret access keys that
+	// are near the words "aws" and "secret"
+	RedactAWSKeys Redactor = NewRegexpRedactor(`\b(?:AKIA|ASIA|ABIA|ACCA)[A-Z0-9]{16}\b|(?i:aws.{0,20}?secret.{0,20}?[:=\s"']+)([A-Za-z0-9/+]{40})\b`)
+
+	// RedactCreditCards redacts credit card numbers with the prefix and length
+	// of a major card network, that pass the Luhn checksum, with or without
+	// spaces or dashes between the digits
+	RedactCreditCards Redactor = creditCardRedactor{
+		candidates: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+	}
+)
+
+// CommonRedactors returns redactors for common secrets and personally
+// identifiable information: JWT's, bearer tokens, AWS keys, credit card
+// numbers, and email addresses.
+func CommonRedactors() []Redactor {
+	return []Redactor{RedactJWTs, RedactBearerTokens, RedactAWSKeys, RedactCreditCards, RedactEmails}
+}
+
+// creditCardRedactor redacts candidate numbers that look like a card number
+// of a major network and pass the Luhn checksum. This leaves most other long
+// numbers alone, such as millisecond timestamps, which start with a 1, but
+// about 1 in 10 long ids with a card prefix will still be redacted.
+type creditCardRedactor struct {
+	candidates *regexp.Regexp
+}
+
+// Redact returns s with all credit card numbers replaced by the replacement
+func (r creditCardRedactor) Redact(s, replacement string) string {
+	return r.candidates.ReplaceAllStringFunc(s, func(match string) string {
+		if digits := onlyDigits(match); cardNetwork(digits) && luhn(digits) {
+			return replacement
+		}
+		return match
+	})
+}
+
+// onlyDigits returns s without its spaces and dashes
+func onlyDigits(s string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(s)
+}
+
+// cardNetwork returns true if the digits have the prefix and length of a card
+// number of Visa, Mastercard, American Express, Discover, Diners Club, JCB, or UnionPay
+func cardNetwork(digits string) bool {
+	n := len(digits)
+	prefix := func(length int) int {
+		p, _ := strconv.Atoi(digits[:length])
+		return p
+	}
+	switch p2 := prefix(2); {
+	case digits[0] == '4': // Visa
+		return n == 13 || n == 16 || n == 19
+	case p2 >= 51 && p2 <= 55, prefix(4) >= 2221 && prefix(4) <= 2720: // Mastercard
+		return n == 16
+	case p2 == 34 || p2 == 37: // American Express
+		return n == 15
+	case prefix(4) == 6011, prefix(3) >= 644 && prefix(3) <= 649, p2 == 65: // Discover
+		return n >= 16 && n <= 19
+	case prefix(4) >= 3528 && prefix(4) <= 3589: // JCB
+		return n >= 16 && n <= 19
+	case p2 == 62: // UnionPay
+		return n >= 16 && n <= 19
+	case p2 == 36 || p2 == 38 || p2 == 39, prefix(3) >= 300 && prefix(3) <= 305: // Diners Club
+		return n >= 14 && n <= 19
+	}
+	return false
+}
+
+// luhn returns true if the digits in s pass the Luhn checksum
+func luhn(s string) bool {
+	var sum int
+	var double bool
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+		d := int(s[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// redaction applies a handler's redaction options to a bug
+type redaction struct {
+	keys             []string
+	useParamsFilters bool
+	values           []Redactor
+	keyMatch         KeyMatch
+	replacement      string
+}
+
+// newRedaction returns a redaction for the options. Safe to call with nil options.
+func newRedaction(opts *RedactOptions) *redaction {
+	if opts == nil {
+		opts = &RedactOptions{}
+	}
+	replacement := opts.Replacement
+	if replacement == "" {
+		replacement = filtered
+	}
+	return &redaction{
+		keys:             opts.Keys,
+		useParamsFilters: !opts.IgnoreParamsFilters,
+		values:           opts.Values,
+		keyMatch:         opts.KeyMatch,
+		replacement:      replacement,
+	}
+}
+
+// enabled returns true if there are any value redactors to apply
+func (r *redaction) enabled() bool {
+	return r != nil && len(r.values) > 0
+}
+
+// paramsFilters returns the notifier's ParamsFilters, if they should be used
+func (r *redaction) paramsFilters(notifier *bugsnag.Notifier) []string {
+	if !r.useParamsFilters {
+		return nil
+	}
+	return notifier.Config.ParamsFilters
+}
+
+// redactKey returns true if the key matches any of the redaction's own key
+// filters, or any of the additional filters
+func (r *redaction) redactKey(key string, filters []string) bool {
+	if key == "" {
+		return false
+	}
+	key = strings.ToLower(key)
+	return r.matchKey(key, r.keys) || r.matchKey(key, filters)
+}
+
+// matchKey returns true if the lowercase key matches any of the filters
+func (r *redaction) matchKey(key string, filters []string) bool {
+	for _, filter := range filters {
+		filter = strings.ToLower(filter)
+		switch r.keyMatch {
+		case KeyMatchGlob:
+			if globMatch(filter, key) {
+				return true
+			}
+		default:
+			if strings.Contains(key, filter) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// globMatch reports whether the whole string matches the pattern, where '*'
+// matches any run of characters and '?' matches any single character.
+func globMatch(pattern, s string) bool {
+	// Position to backtrack to, when a '*' has been seen
+	star, backtrack := -1, 0
+	p := 0
+	for i := 0; i < len(s); {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, backtrack = p, i
+			p++
+		case star >= 0:
+			p = star + 1
+			backtrack++
+			i = backtrack
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// redactString applies all value redactors to the string
+func (r *redaction) redactString(s string) string {
+	for _, rd := range r.values {
+		s = rd.Redact(s, r.replacement)
+	}
+	return s
+}
+
+// redactEvent is a bugsnag callback that redacts the message and context of
+// the event, and the messages of the error's causes.
+func (r *redaction) redactEvent(event *bugsnag.Event) {
+	event.Message = r.redactString(event.Message)
+	event.Context = r.redactString(event.Context)
+
+	if event.Error == nil {
+		return
+	}
+
+	// Copy each link in the chain of causes, because they may belong to the
+	// user's original error, then replace the messages that contain secrets
+	e := *event.Error
+	event.Error = &e
+	for parent := event.Error; parent.Cause != nil; parent = parent.Cause {
+		cause := *parent.Cause
+		msg := cause.Error()
+		if redacted := r.redactString(msg); redacted != msg {
+			cause.Err = redactedError{error: cause.Err, msg: redacted}
+		}
+		parent.Cause = &cause
+	}
+}
@@ -0,0 +1,146 @@
+package slogbugsnag
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strings"
+)
+
+// RouteRule sends the bugs it matches through its own NotifierWorkers, such
+// as the bugsnag project of the team that owns the code that logged them.
+// All of the rule's non-empty conditions must match.
+type RouteRule struct {
+	// PackagePrefix matches records logged from a package with this import
+	// path, or from any package below it.
+	PackagePrefix string
+
+	// Group matches records with a group with this name, whether it was
+	// opened on the logger or is one of the record's attributes.
+	Group string
+
+	// AttrKey matches records with an attribute with this key, at any depth.
+	// If AttrValue is not empty, the attribute's value must also equal it,
+	// once formatted as a string.
+	AttrKey   string
+	AttrValue string
+
+	// Notifiers is the worker pool that the matching bugs are sent through.
+	Notifiers *NotifierWorkers
+}
+
+// route returns the NotifierWorkers that the record's bug should be sent through
+func (h *Handler) route(ctx context.Context, r slog.Record) *NotifierWorkers {
+	if h.routeFn != nil {
+		if nw := h.routeFn(ctx, r); nw != nil {
+			if h.routed != nil && nw != h.notifiers {
+				h.routed.LoadOrStore(nw, struct{}{})
+			}
+			return nw
+		}
+	}
+	if len(h.routeRules) == 0 {
+		return h.notifiers
+	}
+
+	var pkg string
+	var attrs []slog.Attr
+	for _, rule := range h.routeRules {
+		if rule.Notifiers == nil {
+			continue
+		}
+		if rule.PackagePrefix != "" {
+			if pkg == "" {
+				pkg = sourcePackage(r.PC)
+			}
+			if pkg != rule.PackagePrefix && !strings.HasPrefix(pkg, rule.PackagePrefix+"/") {
+				continue
+			}
+		}
+		if rule.Group != "" || rule.AttrKey != "" {
+			if attrs == nil {
+				attrs = h.collectAttrs(r)
+			}
+			if rule.Group != "" && !hasGroup(attrs, rule.Group) {
+				continue
+			}
+			if rule.AttrKey != "" && !hasAttr(attrs, rule.AttrKey, rule.AttrValue) {
+				continue
+			}
+		}
+		return rule.Notifiers
+	}
+	return h.notifiers
+}
+
+// allNotifiers returns the handler's NotifierWorkers, along with those of its
+// route rules, and those returned by its route func so far, without duplicates
+func (h *Handler) allNotifiers() []*NotifierWorkers {
+	all := []*NotifierWorkers{h.notifiers}
+	add := func(nw *NotifierWorkers) {
+		for _, seen := range all {
+			if seen == nw {
+				return
+			}
+		}
+		all = append(all, nw)
+	}
+	for _, rule := range h.routeRules {
+		if rule.Notifiers != nil {
+			add(rule.Notifiers)
+		}
+	}
+	if h.routed != nil {
+		h.routed.Range(func(key, _ any) bool {
+			add(key.(*NotifierWorkers))
+			return true
+		})
+	}
+	return all
+}
+
+// sourcePackage returns the import path of the package of the function at the pc
+func sourcePackage(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	fn := frame.Function
+	// The package path ends at the first dot after the last slash,
+	// ie: "github.com/org/repo/pkg.(*Type).Method"
+	lastSlash := strings.LastIndexByte(fn, '/')
+	if dot := strings.IndexByte(fn[lastSlash+1:], '.'); dot >= 0 {
+		return fn[:lastSlash+1+dot]
+	}
+	return fn
+}
+
+// hasGroup returns true if there is a group with the name among the attributes, at any depth
+func hasGroup(attrs []slog.Attr, name string) bool {
+	for _, a := range attrs {
+		if a.Value.Kind() != slog.KindGroup {
+			continue
+		}
+		if a.Key == name || hasGroup(a.Value.Group(), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAttr returns true if there is an attribute with the key among the
+// attributes, at any depth, with the value if it is not empty
+func hasAttr(attrs []slog.Attr, key, value string) bool {
+	for _, a := range attrs {
+		if a.Value.Kind() == slog.KindGroup {
+			if hasAttr(a.Value.Group(), key, value) {
+				return true
+			}
+			continue
+		}
+		if a.Key == key && (value == "" || a.Value.Resolve().String() == value) {
+			return true
+		}
+	}
+	return false
+}
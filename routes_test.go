@@ -0,0 +1,138 @@
+package slogbugsnag
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"testing"
+)
+
+func TestRoutes(t *testing.T) {
+	t.Parallel()
+
+	newNotifiers := func() (*bugsnagTestServer, *NotifierWorkers) {
+		svr := newBugsnagTestServer(t)
+		return svr, NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 1})
+	}
+	defaultSvr, defaultNotifiers := newNotifiers()
+	pkgSvr, pkgNotifiers := newNotifiers()
+	groupSvr, groupNotifiers := newNotifiers()
+	attrSvr, attrNotifiers := newNotifiers()
+	fnSvr, fnNotifiers := newNotifiers()
+
+	type routeKey struct{}
+	h := NewHandler(&testHandler{}, &HandlerOptions{
+		Notifiers: defaultNotifiers,
+		Route: func(ctx context.Context, r slog.Record) *NotifierWorkers {
+			if ctx.Value(routeKey{}) != nil {
+				return fnNotifiers
+			}
+			return nil
+		},
+		RouteRules: []RouteRule{
+			{PackagePrefix: "github.com/veqryn/slog-bug", Notifiers: pkgNotifiers}, // Not a package boundary
+			{Group: "billing", Notifiers: groupNotifiers},
+			{AttrKey: "team", AttrValue: "search", Notifiers: attrNotifiers},
+			{PackagePrefix: "github.com/veqryn", AttrKey: "pkg", Notifiers: pkgNotifiers},
+		},
+	})
+	logger := slog.New(h)
+
+	logger.Error("default")
+	logger.Error("default value", "team", "ads")
+	logger.WithGroup("billing").Error("group from handler")
+	logger.Error("group from record", slog.Group("billing", "id", 1))
+	logger.Error("attr", slog.Group("g", "team", "search"))
+	logger.Error("pkg", "pkg", true)
+	logger.ErrorContext(context.WithValue(context.Background(), routeKey{}, true), "route func", "team", "search")
+
+	// Closes the pool returned by the route func too
+	h.Close()
+
+	for _, tc := range []struct {
+		name      string
+		svr       *bugsnagTestServer
+		notifiers *NotifierWorkers
+		expected  []string
+	}{
+		{name: "default", svr: defaultSvr, notifiers: defaultNotifiers, expected: []string{"default", "default value"}},
+		{name: "pkg", svr: pkgSvr, notifiers: pkgNotifiers, expected: []string{"pkg"}},
+		{name: "group", svr: groupSvr, notifiers: groupNotifiers, expected: []string{"group from handler", "group from record"}},
+		{name: "attr", svr: attrSvr, notifiers: attrNotifiers, expected: []string{"attr"}},
+		{name: "route func", svr: fnSvr, notifiers: fnNotifiers, expected: []string{"route func"}},
+	} {
+		var contexts []string
+		for _, event := range tc.svr.Events() {
+			contexts = append(contexts, event.Context)
+		}
+		if len(contexts) != len(tc.expected) {
+			t.Errorf("%s: Expected %v; Got: %v", tc.name, tc.expected, contexts)
+			continue
+		}
+		for i := range contexts {
+			if contexts[i] != tc.expected[i] {
+				t.Errorf("%s: Expected %v; Got: %v", tc.name, tc.expected, contexts)
+				break
+			}
+		}
+
+		stats := tc.notifiers.Stats()
+		if stats.Sent != uint64(len(tc.expected)) || stats.Queued != 0 || stats.Dropped != 0 || stats.MaxWorkers != 1 {
+			t.Errorf("%s: Unexpected stats: %+v", tc.name, stats)
+		}
+	}
+}
+
+func TestSharedRoutedNotifiers(t *testing.T) {
+	t.Parallel()
+
+	svr := newBugsnagTestServer(t)
+	shared := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 1})
+	newHandler := func() *Handler {
+		return NewHandler(&testHandler{}, &HandlerOptions{
+			Notifiers:  NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 1}),
+			RouteRules: []RouteRule{{Group: "shared", Notifiers: shared}},
+			Route: func(ctx context.Context, r slog.Record) *NotifierWorkers {
+				return shared
+			},
+		})
+	}
+	h1, h2 := newHandler(), newHandler()
+	slog.New(h1).Error("first")
+	slog.New(h2).Error("second")
+
+	// Each handler closes the shared pool, and so does its owner
+	h1.Close()
+	h2.Close()
+	shared.Close()
+
+	if stats := shared.Stats(); stats.Sent != 2 || stats.Queued != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if events := svr.Events(); len(events) != 2 {
+		t.Error("Expected 2 bugsnag events; Got:", len(events))
+	}
+}
+
+func TestSourcePackage(t *testing.T) {
+	t.Parallel()
+
+	pc, _, _, _ := runtime.Caller(0)
+	if pkg := sourcePackage(pc); pkg != "github.com/veqryn/slog-bugsnag" {
+		t.Error("Unexpected package:", pkg)
+	}
+
+	if pkg := sourcePackage((&testHandler{}).pc()); pkg != "github.com/veqryn/slog-bugsnag" {
+		t.Error("Unexpected package:", pkg)
+	}
+
+	if pkg := sourcePackage(0); pkg != "" {
+		t.Error("Unexpected package:", pkg)
+	}
+}
+
+// pc returns its own pc, which is inside a method
+func (h *testHandler) pc() uintptr {
+	pc, _, _, _ := runtime.Caller(0)
+	return pc
+}
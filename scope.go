@@ -0,0 +1,144 @@
+package slogbugsnag
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+const (
+	// scopeTab is the bugsnag metadata tab that a scope's attributes go in
+	scopeTab = "scope"
+
+	// trailTabName is the bugsnag metadata tab that a scope's trail of records go in
+	trailTabName = "trail"
+
+	// scopeCapacity is the maximum number of records kept in a scope's trail
+	scopeCapacity = 100
+)
+
+// scopeCtxKey is the context key for a scope
+type scopeCtxKey struct{}
+
+// scope collects the records and attributes logged during a request or job
+type scope struct {
+	done  <-chan struct{}
+	trail breadcrumbBuffer
+
+	mu    sync.Mutex
+	attrs []slog.Attr
+	ended bool
+}
+
+// scopeSnapshot is a copy of a scope's attributes and trail, taken when a bug is logged
+type scopeSnapshot struct {
+	attrs []slog.Attr
+	trail []breadcrumb
+}
+
+// NewScope returns a copy of the context with a new scope, which collects
+// everything logged with the context (or its children) during a request or job.
+// Each record below the NotifyLevel is kept in the scope's trail, along with
+// its attributes, and any attributes added with [AddScopeAttrs].
+// Bugs logged with the same context include the scope's attributes in a
+// "scope" metadata tab, and its trail of records, oldest first, in a "trail"
+// metadata tab. The trail keeps the latest 100 records.
+// The scope ends, and its memory is released, when the context is done.
+func NewScope(ctx context.Context) context.Context {
+	s := &scope{done: ctx.Done()}
+	ctx = context.WithValue(ctx, scopeCtxKey{}, s)
+	context.AfterFunc(ctx, s.release)
+	return ctx
+}
+
+// AddScopeAttrs adds the attributes to the context's scope, to be included
+// in any bug logged with the context. It does nothing if the context has no
+// scope, or if the scope has ended.
+func AddScopeAttrs(ctx context.Context, attrs ...slog.Attr) {
+	s := scopeFromContext(ctx)
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.attrs = append(s.attrs, attrs...)
+	}
+}
+
+// scopeFromContext returns the context's scope, or nil if there is none
+func scopeFromContext(ctx context.Context) *scope {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(scopeCtxKey{}).(*scope)
+	if s == nil || s.hasEnded() {
+		return nil
+	}
+	return s
+}
+
+// hasEnded returns true if the scope's context is done.
+// The scope's memory is released asynchronously, after it ends.
+func (s *scope) hasEnded() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// add keeps the record in the scope's trail
+func (s *scope) add(h *Handler, r slog.Record) {
+	s.trail.add(breadcrumb{h: h, record: r.Clone()}, scopeCapacity)
+}
+
+// snapshot returns a copy of the scope's attributes and trail.
+// Safe to call on a nil scope.
+func (s *scope) snapshot() *scopeSnapshot {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	attrs := s.attrs[:len(s.attrs):len(s.attrs)]
+	s.mu.Unlock()
+	return &scopeSnapshot{attrs: attrs, trail: s.trail.snapshot()}
+}
+
+// release ends the scope, dropping its attributes and trail
+func (s *scope) release() {
+	s.mu.Lock()
+	s.attrs, s.ended = nil, true
+	s.mu.Unlock()
+	s.trail.release()
+}
+
+// claimScopeTabs claims the tabs for the scope's attributes and trail, if it has any
+func (b *metaDataBuilder) claimScopeTabs(snap *scopeSnapshot) (attrsTab, trailTab string) {
+	if snap == nil {
+		return "", ""
+	}
+	if len(snap.attrs) > 0 {
+		attrsTab = b.claimTab(scopeTab)
+	}
+	if len(snap.trail) > 0 {
+		trailTab = b.claimTab(trailTabName)
+	}
+	return attrsTab, trailTab
+}
+
+// addScope adds the scope's attributes and trail to their own tabs
+func (b *metaDataBuilder) addScope(attrsTab, trailTab string, snap *scopeSnapshot) {
+	if snap == nil {
+		return
+	}
+	if len(snap.attrs) > 0 {
+		attrs := map[string]any{}
+		b.flattenAttrs(attrs, "", nil, snap.attrs, 0)
+		for k, v := range attrs {
+			b.md.Add(attrsTab, k, v)
+		}
+	}
+	b.addBreadcrumbs(trailTab, snap.trail, false)
+}
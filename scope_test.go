@@ -0,0 +1,71 @@
+package slogbugsnag
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestScope(t *testing.T) {
+	t.Parallel()
+
+	svr := newBugsnagTestServer(t)
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: svr.Notifier(), MaxNotifierConcurrency: 1})
+	logger := slog.New(NewHandler(&testHandler{}, &HandlerOptions{Notifiers: notifiers}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = NewScope(ctx)
+	AddScopeAttrs(ctx, slog.String("route", "/users"), slog.Group("req", slog.String("id", "abc"), slog.String("password", "abc123")))
+
+	logger.DebugContext(ctx, "debug", "a", 1, "b", 2)
+	logger.WithGroup("g").InfoContext(ctx, "info", "c", "long value")
+	logger.Info("no scope")
+	logger.ErrorContext(ctx, "error1")
+
+	// After the scope ends, nothing more is collected
+	cancel()
+	AddScopeAttrs(ctx, slog.String("after", "end"))
+	logger.InfoContext(ctx, "after end")
+	logger.ErrorContext(ctx, "error2")
+
+	notifiers.Close()
+
+	events := svr.Events()
+	if len(events) != 2 {
+		t.Fatal("Expected 2 bugsnag events; Got:", len(events))
+	}
+
+	for _, event := range events {
+		switch event.Context {
+		case "error1":
+			expectedAttrs := map[string]any{"route": "/users", "req.id": "abc", "req.password": "[FILTERED]"}
+			if !reflect.DeepEqual(event.MetaData["scope"], expectedAttrs) {
+				t.Errorf("Expected: %#+v\nGot: %#+v\n", expectedAttrs, event.MetaData["scope"])
+			}
+
+			trail := event.MetaData["trail"]
+			expectedTrail := map[string]any{
+				"0": map[string]any{"level": "DEBUG", "msg": "debug", "attrs": map[string]any{"a": float64(1), "b": float64(2)}},
+				"1": map[string]any{"level": "INFO", "msg": "info", "attrs": map[string]any{"g.c": "long value"}},
+			}
+			for _, entry := range trail {
+				delete(entry.(map[string]any), "time")
+			}
+			if !reflect.DeepEqual(trail, expectedTrail) {
+				t.Errorf("Expected: %#+v\nGot: %#+v\n", expectedTrail, trail)
+			}
+
+		case "error2":
+			if _, ok := event.MetaData["scope"]; ok {
+				t.Errorf("Expected no scope; Got: %#+v\n", event.MetaData["scope"])
+			}
+			if _, ok := event.MetaData["trail"]; ok {
+				t.Errorf("Expected no trail; Got: %#+v\n", event.MetaData["trail"])
+			}
+
+		default:
+			t.Error("Unexpected event:", event.Context)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package slogbugsnag
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+	"github.com/bugsnag/bugsnag-go/v2/device"
+	"github.com/bugsnag/bugsnag-go/v2/headers"
+	"github.com/bugsnag/bugsnag-go/v2/sessions"
+)
+
+// sessionPayloadVersion is the version of the payload sent to the sessions endpoint
+const sessionPayloadVersion = "1.0"
+
+// SessionTrackerOptions are options for a SessionTracker
+type SessionTrackerOptions struct {
+	// Notifier's configuration is used for the session tracker's api key,
+	// sessions endpoint, release stage, app version, and so on.
+	// It should be the same notifier as the NotifierWorkers use.
+	// If nil, the global bugsnag.Config is used.
+	Notifier *bugsnag.Notifier
+
+	// PublishInterval is how often the sessions are sent to bugsnag.
+	// If zero, bugsnag.DefaultSessionPublishInterval is used.
+	PublishInterval time.Duration
+}
+
+// SessionTracker starts bugsnag sessions, for bugsnag to calculate the
+// stability score of the application: the share of sessions (requests or jobs)
+// without any unhandled bugs.
+// Bugs logged with a context holding a session are counted in that session,
+// as handled or unhandled depending on the handler's UnhandledLevel.
+//
+// Unlike bugsnag's global session tracker, a SessionTracker has its own
+// endpoint and publish interval, doesn't handle any signals, and can be
+// flushed and closed.
+//
+// A nil *SessionTracker uses bugsnag's global session tracker, if the global
+// bugsnag config AutoCaptureSessions is on.
+type SessionTracker struct {
+	cfg    bugsnag.Configuration
+	client *http.Client
+
+	mu       sync.Mutex
+	sessions []*sessions.Session
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewSessionTracker creates a SessionTracker, which sends sessions to bugsnag
+// in the background, every PublishInterval, until it is closed.
+// Call [SessionTracker.Close] before the application exits, so that the last
+// sessions are not lost.
+// If opts is nil, the default options are used.
+func NewSessionTracker(opts *SessionTrackerOptions) *SessionTracker {
+	if opts == nil {
+		opts = &SessionTrackerOptions{}
+	}
+	cfg := bugsnag.Config
+	if opts.Notifier != nil && opts.Notifier.Config != nil {
+		cfg = *opts.Notifier.Config
+	}
+	if opts.PublishInterval <= 0 {
+		opts.PublishInterval = bugsnag.DefaultSessionPublishInterval
+	}
+
+	t := &SessionTracker{
+		cfg:    cfg,
+		client: &http.Client{Transport: cfg.Transport},
+		done:   make(chan struct{}),
+	}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(opts.PublishInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.done:
+				return
+			case <-ticker.C:
+				t.Flush()
+			}
+		}
+	}()
+	return t
+}
+
+// StartSession returns a copy of the context with a new bugsnag session.
+// Every bug logged with the context (or its children) is counted in the session.
+func (t *SessionTracker) StartSession(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if t == nil {
+		if bugsnag.Config.IsAutoCaptureSessions() {
+			return bugsnag.StartSession(ctx)
+		}
+		return ctx
+	}
+
+	session := &sessions.Session{StartedAt: time.Now(), EventCounts: &sessions.EventCounts{}}
+	_, _ = rand.Read(session.ID[:])
+	session.ID[6] = session.ID[6]&0x0f | 0x40 // Version 4
+	session.ID[8] = session.ID[8]&0x3f | 0x80 // Variant 10
+
+	t.mu.Lock()
+	t.sessions = append(t.sessions, session)
+	t.mu.Unlock()
+	return sessionContext{Context: ctx, session: session}
+}
+
+// NewScope returns a copy of the context with both a new bugsnag session
+// and a new scope (see [NewScope]), for a request or job.
+func (t *SessionTracker) NewScope(ctx context.Context) context.Context {
+	return NewScope(t.StartSession(ctx))
+}
+
+// Flush sends all sessions started so far to bugsnag, and blocks until done.
+// Safe to call on a nil SessionTracker, where it does nothing.
+func (t *SessionTracker) Flush() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	started := t.sessions
+	t.sessions = nil
+	t.mu.Unlock()
+
+	if err := t.publish(started); err != nil {
+		t.logf("%v", err)
+	}
+}
+
+// Close stops sending sessions in the background, then sends all sessions
+// started so far, and blocks until done.
+// Call it after the NotifierWorkers have been flushed or closed, before the
+// application exits, so that the last sessions are not lost.
+// Sessions started after Close are only sent by calling Flush.
+// It is safe to call more than once, and on a nil SessionTracker, where it
+// does nothing.
+func (t *SessionTracker) Close() {
+	if t == nil {
+		return
+	}
+	t.closeOnce.Do(func() { close(t.done) })
+	t.wg.Wait()
+	t.Flush()
+}
+
+// publish sends the count of sessions to the sessions endpoint
+func (t *SessionTracker) publish(started []*sessions.Session) error {
+	if len(started) == 0 || t.cfg.Endpoints.Sessions == "" {
+		// Without a sessions endpoint, session tracking is disabled
+		return nil
+	}
+	if len(t.cfg.APIKey) != 32 {
+		return fmt.Errorf("slogbugsnag: invalid bugsnag api key %q for sessions", t.cfg.APIKey)
+	}
+	releaseStage := t.cfg.ReleaseStage
+	if releaseStage != "" && t.cfg.NotifyReleaseStages != nil && !slices.Contains(t.cfg.NotifyReleaseStages, releaseStage) {
+		return nil
+	}
+	if releaseStage == "" {
+		releaseStage = "production"
+	}
+	hostname := t.cfg.Hostname
+	if hostname == "" {
+		hostname = device.GetHostname()
+	}
+
+	payload := sessionPayload{
+		Notifier: sessionNotifier{Name: "Bugsnag Go", URL: "https://github.com/bugsnag/bugsnag-go", Version: bugsnag.Version},
+		App:      sessionApp{Type: t.cfg.AppType, ReleaseStage: releaseStage, Version: t.cfg.AppVersion},
+		Device:   sessionDevice{OsName: runtime.GOOS, Hostname: hostname, RuntimeVersions: device.GetRuntimeVersions()},
+		SessionCounts: []sessionCounts{{
+			StartedAt:       started[0].StartedAt.UTC().Format(time.RFC3339),
+			SessionsStarted: len(started),
+		}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("slogbugsnag: unable to marshal sessions: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.cfg.Endpoints.Sessions, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("slogbugsnag: unable to create sessions request: %w", err)
+	}
+	for k, v := range headers.PrefixedHeaders(t.cfg.APIKey, sessionPayloadVersion) {
+		req.Header.Add(k, v)
+	}
+	res, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("slogbugsnag: unable to send sessions: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("slogbugsnag: expected 202 response status sending sessions, got HTTP %s", res.Status)
+	}
+	return nil
+}
+
+// logf logs to the bugsnag config's logger, like bugsnag does
+func (t *SessionTracker) logf(format string, args ...any) {
+	if t.cfg.Logger != nil {
+		t.cfg.Logger.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// sessionPayload is the payload sent to the sessions endpoint, which is the
+// same as the one bugsnag's own session tracker sends
+type sessionPayload struct {
+	Notifier      sessionNotifier `json:"notifier"`
+	App           sessionApp      `json:"app"`
+	Device        sessionDevice   `json:"device"`
+	SessionCounts []sessionCounts `json:"sessionCounts"`
+}
+
+type sessionNotifier struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Version string `json:"version"`
+}
+
+type sessionApp struct {
+	Type         string `json:"type,omitempty"`
+	ReleaseStage string `json:"releaseStage,omitempty"`
+	Version      string `json:"version,omitempty"`
+}
+
+type sessionDevice struct {
+	OsName          string                  `json:"osName,omitempty"`
+	Hostname        string                  `json:"hostname,omitempty"`
+	RuntimeVersions *device.RuntimeVersions `json:"runtimeVersions"`
+}
+
+type sessionCounts struct {
+	StartedAt       string `json:"startedAt"`
+	SessionsStarted int    `json:"sessionsStarted"`
+}
+
+// sessionsPkgPath is the import path of bugsnag's sessions package
+var sessionsPkgPath = reflect.TypeOf(sessions.Session{}).PkgPath()
+
+// sessionContext is a context holding a session, which bugsnag finds when
+// building the event of a bug logged with the context, and counts the bug in.
+// Bugsnag's context key for sessions is unexported, so it is recognized by
+// its type.
+type sessionContext struct {
+	context.Context
+	session *sessions.Session
+}
+
+// Value returns the session for bugsnag's session key, and otherwise the
+// parent context's value
+func (c sessionContext) Value(key any) any {
+	if t := reflect.TypeOf(key); t != nil && t.Name() == "ctxKey" && t.PkgPath() == sessionsPkgPath {
+		return c.session
+	}
+	return c.Context.Value(key)
+}
+
+// RunJob runs the function as a single job, such as one item of a worker
+// pool or one run of a cron job, with its own bugsnag session and scope.
+// The job's name is used as the bugsnag context of any bug logged with the
+// job's context, unless overridden.
+// An error returned by the function is logged at slog.LevelError, as a handled
+// bug. A panic is recovered and logged at the handler's UnhandledLevel, as an
+// unhandled bug, and returned as a *PanicError.
+// Either way, the error is returned after the bug has been logged.
+// If logger is nil, slog.Default() is used.
+func (t *SessionTracker) RunJob(ctx context.Context, logger *slog.Logger, name string, fn func(ctx context.Context) error) (err error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// The job's scope ends when the job returns
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ctx = t.NewScope(ctx)
+	if name != "" {
+		ctx = ContextWithBugsnagContext(ctx, name)
+	}
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			perr := newPanicError(rec)
+			logPanic(ctx, logger, nil, perr)
+			err = perr
+		}
+	}()
+
+	if err = fn(ctx); err != nil {
+		logger.LogAttrs(ctx, slog.LevelError, "job failed", slog.String("job", name), slog.Any("err", err))
+	}
+	return err
+}
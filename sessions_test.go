@@ -0,0 +1,122 @@
+package slogbugsnag
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSessionTracker(t *testing.T) {
+	t.Parallel()
+
+	svr := newBugsnagTestServer(t)
+	notifier := svr.Notifier()
+	notifiers := NewNotifierWorkers(&NotifierOptions{Notifier: notifier, MaxNotifierConcurrency: 1})
+	logger := slog.New(NewHandler(&testHandler{}, &HandlerOptions{Notifiers: notifiers}))
+	tracker := NewSessionTracker(&SessionTrackerOptions{Notifier: notifier, PublishInterval: time.Hour})
+
+	ctx := context.Background()
+	if err := tracker.RunJob(ctx, logger, "ok job", func(ctx context.Context) error { return nil }); err != nil {
+		t.Error("Expected no error; Got:", err)
+	}
+	if err := tracker.RunJob(ctx, logger, "failing job", func(ctx context.Context) error { return errors.New("failed") }); err == nil || err.Error() != "failed" {
+		t.Error("Expected failed error; Got:", err)
+	}
+	err := tracker.RunJob(ctx, logger, "panicking job", func(ctx context.Context) error {
+		logger.ErrorContext(ctx, "before panic")
+		panic("oh no")
+	})
+	var perr *PanicError
+	if !errors.As(err, &perr) || perr.Value != "oh no" {
+		t.Error("Expected PanicError; Got:", err)
+	}
+
+	h := HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.ErrorContext(r.Context(), "in request")
+	}), &HTTPMiddlewareOptions{Logger: logger, Sessions: tracker})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	logger.Error("no session")
+
+	notifiers.Close()
+	tracker.Close()
+
+	if sessions := svr.Sessions(); sessions != 4 {
+		t.Error("Expected 4 sessions; Got:", sessions)
+	}
+
+	type counts struct{ handled, unhandled int }
+	expected := map[string]*counts{
+		"job failed":   {handled: 1},
+		"panic: oh no": {unhandled: 1}, // Unhandled bugs are sent first
+		"before panic": {handled: 1, unhandled: 1},
+		"in request":   {handled: 1},
+		"no session":   nil,
+	}
+	events := svr.Events()
+	if len(events) != 5 {
+		t.Fatal("Expected 5 bugsnag events; Got:", len(events))
+	}
+	sessionIDs := map[string]bool{}
+	for _, event := range events {
+		msg, _ := event.MetaData["log"]["msg"].(string)
+		exp, ok := expected[msg]
+		if !ok {
+			t.Error("Unexpected event:", msg)
+			continue
+		}
+		if msg != "in request" && msg != "no session" && event.Context != "failing job" && event.Context != "panicking job" {
+			t.Errorf("Expected the job name as the context of %q; Got: %q", msg, event.Context)
+		}
+		if exp == nil {
+			if event.Session != nil {
+				t.Errorf("Expected no session for %q; Got: %#+v\n", msg, event.Session)
+			}
+			continue
+		}
+		if event.Session == nil || event.Session.Events.Handled != exp.handled || event.Session.Events.Unhandled != exp.unhandled {
+			t.Errorf("Expected %+v for %q; Got: %#+v\n", *exp, msg, event.Session)
+			continue
+		}
+		sessionIDs[event.Session.ID] = true
+	}
+	if len(sessionIDs) != 3 {
+		t.Error("Expected events from 3 sessions; Got:", len(sessionIDs))
+	}
+}
+
+func TestSessionTrackerClose(t *testing.T) {
+	t.Parallel()
+
+	svr := newBugsnagTestServer(t)
+	tracker := NewSessionTracker(&SessionTrackerOptions{Notifier: svr.Notifier(), PublishInterval: 5 * time.Millisecond})
+
+	// Sessions are sent in the background
+	tracker.StartSession(context.Background())
+	tracker.StartSession(context.Background())
+	for deadline := time.Now().Add(5 * time.Second); svr.Sessions() < 2 && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+	}
+	if sessions := svr.Sessions(); sessions != 2 {
+		t.Fatal("Expected 2 sessions; Got:", sessions)
+	}
+
+	// Once closed, sessions are only sent by flushing
+	tracker.Close()
+	tracker.Close()
+	tracker.StartSession(context.Background())
+	time.Sleep(20 * time.Millisecond)
+	if sessions := svr.Sessions(); sessions != 2 {
+		t.Error("Expected no sessions sent after closing; Got:", sessions)
+	}
+	tracker.Flush()
+	if sessions := svr.Sessions(); sessions != 3 {
+		t.Error("Expected 3 sessions; Got:", sessions)
+	}
+
+	var nilTracker *SessionTracker
+	nilTracker.Close()
+}
@@ -15,36 +15,40 @@ import (
 )
 
 func TestSlogtest(t *testing.T) {
+	for _, forward := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ForwardGroupsAndAttrs=%t", forward), func(t *testing.T) {
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer svr.Close()
 
-	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}))
-	defer svr.Close()
+			// Set the bugsnag config to send all communication to the test server
+			notifiers := slogbugsnag.NewNotifierWorkers(&slogbugsnag.NotifierOptions{
+				Notifier: bugsnag.New(bugsnag.Configuration{
+					Endpoints: bugsnag.Endpoints{
+						Notify:   svr.URL,
+						Sessions: svr.URL,
+					},
+				}),
+			})
+			defer notifiers.Close()
 
-	// Set the bugsnag config to send all communication to the test server
-	notifiers := slogbugsnag.NewNotifierWorkers(&slogbugsnag.NotifierOptions{
-		Notifier: bugsnag.New(bugsnag.Configuration{
-			Endpoints: bugsnag.Endpoints{
-				Notify:   svr.URL,
-				Sessions: svr.URL,
-			},
-		}),
-	})
+			opts := &slogbugsnag.HandlerOptions{Notifiers: notifiers, ForwardGroupsAndAttrs: forward}
 
-	opts := &slogbugsnag.HandlerOptions{Notifiers: notifiers}
+			var buf bytes.Buffer
+			h := slogbugsnag.NewHandler(slog.NewJSONHandler(&buf, nil), opts)
 
-	var buf bytes.Buffer
-	h := slogbugsnag.NewHandler(slog.NewJSONHandler(&buf, nil), opts)
-
-	results := func() []map[string]any {
-		ms, err := parseLines(buf.Bytes(), parseJSON)
-		if err != nil {
-			t.Fatal(err)
-		}
-		return ms
-	}
-	if err := slogtest.TestHandler(h, results); err != nil {
-		t.Fatal(err)
+			results := func() []map[string]any {
+				ms, err := parseLines(buf.Bytes(), parseJSON)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return ms
+			}
+			if err := slogtest.TestHandler(h, results); err != nil {
+				t.Fatal(err)
+			}
+		})
 	}
 }
 
@@ -0,0 +1,290 @@
+package slogbugsnag
+
+import (
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ValueEncoder converts values of custom types into values that bugsnag can
+// display, such as strings, numbers, bools, map[string]any, and []any.
+// It returns false if it does not handle the value, in which case the default
+// encoding is used. The encoded value is then redacted and limited as usual,
+// and any values nested inside it are passed to the ValueEncoder too.
+type ValueEncoder func(value any) (encoded any, ok bool)
+
+// sanitizeAttrValue returns the resolved attribute value encoded into
+// something readable in bugsnag, for every [slog.Kind], with the key filters
+// applied to any maps and structs inside it, the value redactors applied to
+// any strings in it, and the limits applied.
+func (b *metaDataBuilder) sanitizeAttrValue(value slog.Value) any {
+	switch value.Kind() {
+	case slog.KindString:
+		return b.sanitizeString(value.String())
+	case slog.KindInt64:
+		return value.Int64()
+	case slog.KindUint64:
+		return value.Uint64()
+	case slog.KindFloat64:
+		return encodeFloat(value.Float64())
+	case slog.KindBool:
+		return value.Bool()
+	case slog.KindDuration:
+		return value.Duration().String()
+	case slog.KindTime:
+		return value.Time().Format(time.RFC3339Nano)
+	case slog.KindGroup, slog.KindLogValuer:
+		return b.sanitizeSlogValue(value.Resolve(), 0, nil)
+	default:
+		return b.sanitize(value.Any(), 0, nil)
+	}
+}
+
+// sanitizeString applies the value redactors and the string length limit
+func (b *metaDataBuilder) sanitizeString(s string) string {
+	if b.redaction.enabled() {
+		s = b.redaction.redactString(s)
+	}
+	return b.truncateString(s)
+}
+
+// encodeFloat returns the float, or a string if json can't encode it
+func encodeFloat(f float64) any {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return f
+}
+
+// sanitize returns the value encoded with the ValueEncoder if it handles it,
+// or the default encoding otherwise.
+func (b *metaDataBuilder) sanitize(data any, depth int, seen []uintptr) any {
+	if b.encoder != nil && data != nil {
+		if encoded, ok := b.encoder(data); ok {
+			return b.sanitizeDefault(encoded, depth, seen)
+		}
+	}
+	return b.sanitizeDefault(data, depth, seen)
+}
+
+// sanitizeDefault encodes the value into something readable in bugsnag, with
+// every string inside it sanitized, and the values of any map keys or struct
+// fields that match the key filters replaced.
+// [slog.LogValuer], [error], [time.Time], [fmt.Stringer],
+// [encoding.TextMarshaler], and [json.Marshaler] are honored, in that order.
+// Maps, slices, arrays, structs, and pointers are recursed into and converted
+// to map[string]any and []any. Recursive data structures are replaced, and
+// structs without any exported fields are formatted with fmt.
+// Depth is the nesting depth of the value inside the attribute value, and seen
+// are the pointers of the containers it is nested inside of.
+func (b *metaDataBuilder) sanitizeDefault(data any, depth int, seen []uintptr) any {
+	if data == nil {
+		return nil
+	}
+
+	v := reflect.ValueOf(data)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return data
+		}
+	}
+
+	// Handle certain well known interfaces and types, in order of preference
+	switch t := data.(type) {
+	case string:
+		return b.sanitizeString(t)
+
+	case slog.LogValuer:
+		return b.sanitizeSlogValue(t.LogValue().Resolve(), depth, seen)
+
+	case slog.Value:
+		return b.sanitizeSlogValue(t.Resolve(), depth, seen)
+
+	case error:
+		return b.sanitizeString(t.Error())
+
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+
+	case fmt.Stringer:
+		// This also covers time.Duration
+		return b.sanitizeString(t.String())
+
+	case encoding.TextMarshaler:
+		if text, err := t.MarshalText(); err == nil {
+			return b.sanitizeString(string(text))
+		}
+
+	case json.Marshaler:
+		// Decode the json, so that it is displayed as structured data
+		if text, err := t.MarshalJSON(); err == nil {
+			var decoded any
+			if err = json.Unmarshal(text, &decoded); err == nil {
+				return b.sanitizeDefault(decoded, depth, seen)
+			}
+			return b.sanitizeString(string(text))
+		}
+
+	case []byte:
+		// Readable text is shown as is, and anything else as hex
+		if utf8.Valid(t) {
+			return b.sanitizeString(string(t))
+		}
+		return b.sanitizeString("0x" + hex.EncodeToString(t))
+	}
+
+	// Guard against recursive data structures
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		ptr := v.Pointer()
+		for _, s := range seen {
+			if s == ptr {
+				return "[RECURSION]"
+			}
+		}
+		seen = append(seen[:len(seen):len(seen)], ptr)
+	}
+
+	// Guard against deeply nested containers
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.Struct:
+		if b.limits.tooDeep(depth + 1) {
+			b.truncated.depth++
+			return truncatedDepth
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return b.sanitizeString(v.String())
+
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return data
+
+	case reflect.Float32, reflect.Float64:
+		return encodeFloat(v.Float())
+
+	case reflect.Pointer, reflect.Interface:
+		return b.sanitize(v.Elem().Interface(), depth, seen)
+
+	case reflect.Array, reflect.Slice:
+		ret := make([]any, v.Len())
+		for i := range ret {
+			ret[i] = b.sanitize(v.Index(i).Interface(), depth+1, seen)
+		}
+		return ret
+
+	case reflect.Map:
+		ret := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprintf("%v", iter.Key().Interface())
+			if b.redaction.redactKey(key, b.filters) {
+				ret[key] = b.redaction.replacement
+				continue
+			}
+			ret[key] = b.sanitize(iter.Value().Interface(), depth+1, seen)
+		}
+		return ret
+
+	case reflect.Struct:
+		t := v.Type()
+		if !hasExportedFields(t) {
+			// Nothing would be left of it, so format it instead
+			return b.sanitizeString(fmt.Sprintf("%+v", data))
+		}
+		ret := make(map[string]any, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			field := t.Field(i)
+			// Don't export private fields
+			if !field.IsExported() {
+				continue
+			}
+
+			name, omitEmpty := jsonFieldName(field)
+			if name == "-" {
+				continue
+			}
+			if b.redaction.redactKey(name, b.filters) {
+				ret[name] = b.redaction.replacement
+				continue
+			}
+			val := b.sanitize(v.Field(i).Interface(), depth+1, seen)
+			if str, ok := val.(string); ok && omitEmpty && str == "" {
+				continue
+			}
+			ret[name] = val
+		}
+		return ret
+
+	default:
+		// Things JSON can't serialize, same as bugsnag
+		return "[" + v.Type().String() + "]"
+	}
+}
+
+// sanitizeSlogValue sanitizes a resolved [slog.Value], turning groups into maps
+func (b *metaDataBuilder) sanitizeSlogValue(value slog.Value, depth int, seen []uintptr) any {
+	if value.Kind() != slog.KindGroup {
+		return b.sanitize(value.Any(), depth, seen)
+	}
+
+	if b.limits.tooDeep(depth + 1) {
+		b.truncated.depth++
+		return truncatedDepth
+	}
+
+	ret := map[string]any{}
+	for _, attr := range value.Group() {
+		if b.redaction.redactKey(attr.Key, b.filters) {
+			ret[attr.Key] = b.redaction.replacement
+			continue
+		}
+		resolved := attr.Value.Resolve()
+		val := b.sanitizeSlogValue(resolved, depth+1, seen)
+		// Groups with empty keys are inlined
+		if m, ok := val.(map[string]any); ok && attr.Key == "" && resolved.Kind() == slog.KindGroup {
+			for k, v := range m {
+				ret[k] = v
+			}
+			continue
+		}
+		ret[attr.Key] = val
+	}
+	return ret
+}
+
+// jsonFieldName returns the name of the struct field according to its json
+// tag, and whether it should be omitted when empty
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag, ok := field.Tag.Lookup("json")
+	if !ok || tag == "" {
+		return field.Name, false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, strings.Contains(","+opts+",", ",omitempty,")
+}
+
+// hasExportedFields returns true if the struct type has any exported fields
+func hasExportedFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
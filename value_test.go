@@ -0,0 +1,95 @@
+package slogbugsnag
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bugsnag/bugsnag-go/v2"
+)
+
+type jsonMarshaler struct{}
+
+func (jsonMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`{"a":[1,"b"]}`), nil
+}
+
+type onlyPrivate struct {
+	name string
+	age  int
+}
+
+type cyclic struct {
+	Name string
+	Next *cyclic
+}
+
+type money struct {
+	Cents    int64
+	Currency string
+}
+
+func TestSanitizeAttrValue(t *testing.T) {
+	t.Parallel()
+
+	loop := &cyclic{Name: "loop"}
+	loop.Next = loop
+
+	h := &Handler{
+		notifiers: &NotifierWorkers{notifier: bugsnag.New()},
+		valueEncoder: func(value any) (any, bool) {
+			if m, ok := value.(money); ok {
+				return map[string]any{"amount": float64(m.Cents) / 100, "currency": m.Currency}, true
+			}
+			return nil, false
+		},
+	}
+
+	tests := []struct {
+		name     string
+		value    slog.Value
+		expected any
+	}{
+		{name: "String", value: slog.StringValue("foo"), expected: "foo"},
+		{name: "Int64", value: slog.Int64Value(-5), expected: int64(-5)},
+		{name: "Uint64", value: slog.Uint64Value(5), expected: uint64(5)},
+		{name: "Float64", value: slog.Float64Value(1.5), expected: 1.5},
+		{name: "NaN", value: slog.Float64Value(math.NaN()), expected: "NaN"},
+		{name: "Inf", value: slog.Float64Value(math.Inf(1)), expected: "+Inf"},
+		{name: "Bool", value: slog.BoolValue(true), expected: true},
+		{name: "Duration", value: slog.DurationValue(90 * time.Second), expected: "1m30s"},
+		{name: "Time", value: slog.TimeValue(defaultTime), expected: "2023-09-29T13:00:59Z"},
+		{name: "Group", value: slog.GroupValue(slog.Int("a", 1)), expected: map[string]any{"a": int64(1)}},
+		{name: "LogValuer", value: slog.AnyValue(credentialsValuer{}), expected: map[string]any{"user": "joe", "password": "[FILTERED]"}},
+		{name: "Error", value: slog.AnyValue(errors.New("oops")), expected: "oops"},
+		{name: "Stringer", value: slog.AnyValue(secretStringer{}), expected: "mail me at j@a.com"},
+		{name: "TextMarshaler", value: slog.AnyValue(netip.MustParseAddr("127.0.0.1")), expected: "127.0.0.1"},
+		{name: "JSONMarshaler", value: slog.AnyValue(jsonMarshaler{}), expected: map[string]any{"a": []any{1.0, "b"}}},
+		{name: "Bytes", value: slog.AnyValue([]byte("hello")), expected: "hello"},
+		{name: "BinaryBytes", value: slog.AnyValue([]byte{0xff, 0x00}), expected: "0xff00"},
+		{name: "NestedTimes", value: slog.AnyValue([]any{defaultTime, time.Second}), expected: []any{"2023-09-29T13:00:59Z", "1s"}},
+		{name: "OnlyPrivate", value: slog.AnyValue(onlyPrivate{name: "joe", age: 5}), expected: "{name:joe age:5}"},
+		{name: "Cyclic", value: slog.AnyValue(loop), expected: map[string]any{"Name": "loop", "Next": "[RECURSION]"}},
+		{name: "Channel", value: slog.AnyValue(make(chan int)), expected: "[chan int]"},
+		{name: "Encoder", value: slog.AnyValue(money{Cents: 150, Currency: "USD"}), expected: map[string]any{"amount": 1.5, "currency": "USD"}},
+		{name: "NestedEncoder", value: slog.AnyValue([]money{{Cents: 5, Currency: "EUR"}}), expected: []any{map[string]any{"amount": 0.05, "currency": "EUR"}}},
+	}
+
+	for _, tc := range tests {
+		b := h.newMetaDataBuilder()
+		actual := b.sanitizeAttrValue(tc.value)
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%s: expected %#+v; Got: %#+v", tc.name, tc.expected, actual)
+		}
+
+		// Everything must be encodable to json, or bugsnag will drop the whole bug
+		if _, err := json.Marshal(actual); err != nil {
+			t.Errorf("%s: unable to marshal json: %v", tc.name, err)
+		}
+	}
+}